@@ -0,0 +1,222 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	fs "melodex/firestore"
+	"melodex/scoring"
+	"melodex/store"
+)
+
+// featuringCredit separates the primary artists from the featured ones
+var featuringCredit = regexp.MustCompile(`(?i)\s*[(\[]?\b(?:feat\.?|ft\.?|featuring)\s+`)
+
+// ParseArtists splits a free-text artist, e.g. "Drake, Future & Young Thug
+// feat. Travis Scott", into credits without identifiers
+func ParseArtists(text string) []fs.ArtistCredit {
+	primary, featured := text, ""
+	if loc := featuringCredit.FindStringIndex(text); loc != nil {
+		primary = text[:loc[0]]
+		featured = strings.TrimRight(text[loc[1]:], ")] ")
+	}
+
+	var credits []fs.ArtistCredit
+	for _, name := range splitNames(primary, false) {
+		credits = append(credits, fs.ArtistCredit{Name: name, Role: fs.RolePrimary})
+	}
+	for _, name := range splitNames(featured, true) {
+		credits = append(credits, fs.ArtistCredit{Name: name, Role: fs.RoleFeatured})
+	}
+	return credits
+}
+
+// splitNames splits a list of names like "A, B & C". Since "&" is part of
+// many band names, a single "A & B" is only split when ampersand is set.
+func splitNames(list string, ampersand bool) []string {
+	parts := strings.Split(list, ",")
+	if last := parts[len(parts)-1]; ampersand || len(parts) > 1 {
+		parts = append(parts[:len(parts)-1], strings.Split(last, " & ")...)
+	}
+
+	var names []string
+	for _, part := range parts {
+		if name := strings.TrimSpace(part); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// FillCredits copies the identifiers of from into the credits with the
+// same name, keeping those already set
+func FillCredits(credits, from []fs.ArtistCredit) {
+	for i := range credits {
+		credit := &credits[i]
+		for _, other := range from {
+			if scoring.NormalizeName(other.Name) != scoring.NormalizeName(credit.Name) {
+				continue
+			}
+			if credit.SpotifyID == "" {
+				credit.SpotifyID = other.SpotifyID
+			}
+			if credit.MBID == "" {
+				credit.MBID = other.MBID
+			}
+			if credit.ArtistID == "" {
+				credit.ArtistID = other.ArtistID
+			}
+		}
+	}
+}
+
+// ArtistID returns the melodex ID of a credited artist, derived from its
+// Spotify ID, else its MusicBrainz ID, else its normalized name. Like track
+// IDs, records under a worse ID are merged once a better one shows up.
+func ArtistID(credit fs.ArtistCredit) string {
+	return artistIDs(credit)[0]
+}
+
+// artistIDs returns every melodex ID an artist may be stored under, best first
+func artistIDs(credit fs.ArtistCredit) []string {
+	var ids []string
+	if spotifyID := strings.TrimSpace(credit.SpotifyID); spotifyID != "" {
+		ids = append(ids, hashID("mxa", "spotify:"+spotifyID))
+	}
+	if mbid := strings.TrimSpace(credit.MBID); mbid != "" {
+		ids = append(ids, hashID("mxa", "mbid:"+strings.ToLower(mbid)))
+	}
+	return append(ids, hashID("mxa", "name:"+scoring.NormalizeName(credit.Name)))
+}
+
+// updateArtists adds collection and the tracks crediting each artist, by
+// the ID of their live record, to the artist records. merged maps track IDs
+// merged into another record to that record's ID.
+func updateArtists(ctx context.Context, st store.Store, collection, date string, tracks []fs.Track, merged map[string]string, now time.Time) error {
+	credited := make(map[string][]string) // artist ID -> track IDs
+	credits := make(map[string]fs.ArtistCredit)
+	var ids []string
+	for _, track := range tracks {
+		for _, credit := range track.Artists {
+			id := credit.ArtistID
+			if id == "" {
+				id = ArtistID(credit)
+			}
+			if _, seen := credited[id]; !seen {
+				ids = append(ids, id)
+				credits[id] = credit
+			}
+			credited[id] = append(credited[id], track.TrackID)
+		}
+	}
+
+	for _, id := range ids {
+		if err := updateArtist(ctx, st, id, credits[id], credited[id], collection, date, merged, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateArtist adds collection and trackIDs to the live record of id,
+// merging the records under the artist's other IDs into it
+func updateArtist(ctx context.Context, st store.Store, id string, credit fs.ArtistCredit, trackIDs []string, collection, date string, merged map[string]string, now time.Time) error {
+	for attempt := 0; ; attempt++ {
+		target := id
+		artist, err := GetArtist(ctx, st, id)
+		if err == nil {
+			target = artist.ID
+		} else if !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("reading artist %s: %w", id, err)
+		}
+		aliases := otherIDs(artistIDs(credit), id, target)
+
+		err = st.UpdateArtists(ctx, append([]string{target}, aliases...), func(records map[string]*fs.Artist) error {
+			artist, ok := records[target]
+			if !ok {
+				artist = &fs.Artist{ID: target, FirstSeen: date, LastSeen: date}
+				records[target] = artist
+			}
+			if artist.MergedInto != "" {
+				return errMerged
+			}
+			for _, alias := range aliases {
+				old, ok := records[alias]
+				if ok && old.MergedInto != "" {
+					continue
+				}
+				if ok {
+					mergeArtist(artist, *old)
+				}
+				records[alias] = &fs.Artist{ID: alias, MergedInto: target, UpdatedAt: now}
+			}
+
+			mergeArtist(artist, fs.Artist{
+				Name:      credit.Name,
+				SpotifyID: credit.SpotifyID,
+				MBID:      credit.MBID,
+				Sources:   []string{collection},
+				Tracks:    trackIDs,
+				FirstSeen: date,
+				LastSeen:  date,
+			})
+			var live []string
+			for _, trackID := range artist.Tracks {
+				if to, ok := merged[trackID]; ok {
+					trackID = to
+				}
+				live = addString(live, trackID)
+			}
+			artist.Tracks = live
+			artist.UpdatedAt = now
+			return nil
+		})
+		if errors.Is(err, errMerged) && attempt < maxMerges {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("saving artist %s: %w", target, err)
+		}
+		return nil
+	}
+}
+
+// mergeArtist adds the sources and tracks of other to artist and fills in
+// identifiers the artist is missing
+func mergeArtist(artist *fs.Artist, other fs.Artist) {
+	if artist.Name == "" {
+		artist.Name = other.Name
+	}
+	if artist.SpotifyID == "" {
+		artist.SpotifyID = other.SpotifyID
+	}
+	if artist.MBID == "" {
+		artist.MBID = other.MBID
+	}
+	for _, source := range other.Sources {
+		artist.Sources = addString(artist.Sources, source)
+	}
+	sort.Strings(artist.Sources)
+	for _, trackID := range other.Tracks {
+		artist.Tracks = addString(artist.Tracks, trackID)
+	}
+	if other.FirstSeen != "" {
+		artist.FirstSeen = min(artist.FirstSeen, other.FirstSeen)
+		artist.LastSeen = max(artist.LastSeen, other.LastSeen)
+	}
+}
+
+// addString appends s to list unless it is already there
+func addString(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,116 @@
+package catalog
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	fs "melodex/firestore"
+	"melodex/store"
+)
+
+func TestParseArtists(t *testing.T) {
+	primary := func(name string) fs.ArtistCredit { return fs.ArtistCredit{Name: name, Role: fs.RolePrimary} }
+	featured := func(name string) fs.ArtistCredit { return fs.ArtistCredit{Name: name, Role: fs.RoleFeatured} }
+
+	tests := []struct {
+		text string
+		want []fs.ArtistCredit
+	}{
+		{"SZA", []fs.ArtistCredit{primary("SZA")}},
+		{"Drake Featuring 21 Savage & Project Pat", []fs.ArtistCredit{primary("Drake"), featured("21 Savage"), featured("Project Pat")}},
+		{"Metro Boomin, Future & Travis Scott", []fs.ArtistCredit{primary("Metro Boomin"), primary("Future"), primary("Travis Scott")}},
+		{"Simon & Garfunkel", []fs.ArtistCredit{primary("Simon & Garfunkel")}},
+		{"Gunna (feat. Burna Boy)", []fs.ArtistCredit{primary("Gunna"), featured("Burna Boy")}},
+		{"SZA ft. Travis Scott, Future", []fs.ArtistCredit{primary("SZA"), featured("Travis Scott"), featured("Future")}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := ParseArtists(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseArtists(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestArtistID(t *testing.T) {
+	spotify := ArtistID(fs.ArtistCredit{Name: "SZA", SpotifyID: "7tYKF4w9nC0nq9CsPZTHyP", MBID: "a1b2"})
+	if spotify != ArtistID(fs.ArtistCredit{Name: "Sza", SpotifyID: "7tYKF4w9nC0nq9CsPZTHyP"}) {
+		t.Error("Expected the Spotify ID to decide the ID")
+	}
+	if ArtistID(fs.ArtistCredit{Name: "Beyoncé"}) != ArtistID(fs.ArtistCredit{Name: "beyonce"}) {
+		t.Error("Expected artists without identifiers to share the ID of their normalized name")
+	}
+}
+
+func TestUpdate_Artists(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+	sza := fs.ArtistCredit{Name: "SZA", Role: fs.RolePrimary, SpotifyID: "sza"}
+	tracks := []fs.Track{
+		{Rank: 1, Artist: "SZA", Title: "Saturn", Artists: []fs.ArtistCredit{sza}},
+		{Rank: 2, Artist: "SZA feat. Kendrick Lamar", Title: "Doves", Artists: []fs.ArtistCredit{sza, {Name: "Kendrick Lamar", Role: fs.RoleFeatured}}},
+	}
+	Assign(tracks)
+	if tracks[1].Artists[0].ArtistID != tracks[0].Artists[0].ArtistID {
+		t.Fatalf("Expected both credits of SZA to get one ID, got %+v", tracks)
+	}
+
+	if err := Update(ctx, st, "billboard", "2024-02-04", tracks); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := Update(ctx, st, "hnhh", "2024-02-05", tracks[:1]); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	artist, err := st.GetArtist(ctx, tracks[0].Artists[0].ArtistID)
+	if err != nil {
+		t.Fatalf("GetArtist: %v", err)
+	}
+	want := fs.Artist{
+		ID: tracks[0].Artists[0].ArtistID, Name: "SZA", SpotifyID: "sza",
+		Sources: []string{"billboard", "hnhh"}, Tracks: []string{tracks[0].TrackID, tracks[1].TrackID},
+		FirstSeen: "2024-02-04", LastSeen: "2024-02-05",
+	}
+	artist.UpdatedAt = want.UpdatedAt
+	if !reflect.DeepEqual(artist, want) {
+		t.Errorf("Unexpected artist\ngot:  %+v\nwant: %+v", artist, want)
+	}
+
+	kendrick, err := st.GetArtist(ctx, tracks[1].Artists[1].ArtistID)
+	if err != nil || len(kendrick.Tracks) != 1 {
+		t.Errorf("Expected the featured artist to be credited on one track, got %+v, %v", kendrick, err)
+	}
+
+	record, _ := st.GetTrack(ctx, tracks[1].TrackID)
+	if len(record.Artists) != 2 || record.Artists[1].Role != fs.RoleFeatured {
+		t.Errorf("Expected the canonical track to keep its credits, got %+v", record.Artists)
+	}
+}
+
+func TestUpdate_MergesArtistOnBetterIdentifier(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+	byName := []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn", Artists: []fs.ArtistCredit{{Name: "SZA", Role: fs.RolePrimary}}}}
+	Assign(byName)
+	if err := Update(ctx, st, "billboard", "2024-02-04", byName); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	bySpotify := []fs.Track{{Rank: 1, Artist: "SZA", Title: "Snooze", Artists: []fs.ArtistCredit{{Name: "SZA", Role: fs.RolePrimary, SpotifyID: "sza"}}}}
+	Assign(bySpotify)
+	if err := Update(ctx, st, "hnhh", "2024-02-05", bySpotify); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	artist, err := GetArtist(ctx, st, byName[0].Artists[0].ArtistID)
+	if err != nil {
+		t.Fatalf("GetArtist: %v", err)
+	}
+	if artist.ID != bySpotify[0].Artists[0].ArtistID || artist.SpotifyID != "sza" {
+		t.Errorf("Expected the name ID to lead to the Spotify record, got %+v", artist)
+	}
+	if !reflect.DeepEqual(artist.Tracks, []string{byName[0].TrackID, bySpotify[0].TrackID}) || artist.FirstSeen != "2024-02-04" {
+		t.Errorf("Expected the tracks of both records, got %+v", artist)
+	}
+}
@@ -0,0 +1,307 @@
+package catalog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	fs "melodex/firestore"
+	"melodex/scoring"
+	"melodex/store"
+)
+
+// ID returns the melodex ID of a track, derived from its ISRC, else its
+// MusicBrainz ID, else its normalized artist and title. When a track gets
+// a better identifier, Update merges the record under its old ID into the
+// new one, which keeps the old ID as an alias.
+func ID(track fs.Track) string {
+	return trackIDs(track)[0]
+}
+
+// trackIDs returns every melodex ID a track may be stored under, best first
+func trackIDs(track fs.Track) []string {
+	var ids []string
+	if isrc := strings.TrimSpace(track.ISRC); isrc != "" {
+		ids = append(ids, hashID("mx", "isrc:"+strings.ToUpper(isrc)))
+	}
+	if mbid := strings.TrimSpace(track.MBID); mbid != "" {
+		ids = append(ids, hashID("mx", "mbid:"+strings.ToLower(mbid)))
+	}
+	return append(ids, hashID("mx", "key:"+scoring.TrackKey(track.Artist, track.Title)))
+}
+
+// hashID derives a melodex ID from the identifier in basis
+func hashID(prefix, basis string) string {
+	sum := sha256.Sum256([]byte(basis))
+	return prefix + hex.EncodeToString(sum[:8])
+}
+
+// maxMerges bounds how many merged records are followed to find a live one
+const maxMerges = 3
+
+// GetTrack returns the canonical record of a track by any of its melodex
+// IDs, following merged records to the one they were merged into
+func GetTrack(ctx context.Context, st store.Store, id string) (fs.CanonicalTrack, error) {
+	for i := 0; ; i++ {
+		track, err := st.GetTrack(ctx, id)
+		if err != nil || track.MergedInto == "" || i == maxMerges {
+			return track, err
+		}
+		id = track.MergedInto
+	}
+}
+
+// GetArtist is GetTrack for artists
+func GetArtist(ctx context.Context, st store.Store, id string) (fs.Artist, error) {
+	for i := 0; ; i++ {
+		artist, err := st.GetArtist(ctx, id)
+		if err != nil || artist.MergedInto == "" || i == maxMerges {
+			return artist, err
+		}
+		id = artist.MergedInto
+	}
+}
+
+// Assign sets the TrackID of tracks and the ArtistID of their credits, so
+// daily documents reference their canonical records
+func Assign(tracks []fs.Track) {
+	for i := range tracks {
+		tracks[i].TrackID = ID(tracks[i])
+		for j := range tracks[i].Artists {
+			tracks[i].Artists[j].ArtistID = ArtistID(tracks[i].Artists[j])
+		}
+	}
+}
+
+// Update merges the snapshot of collection on date into the canonical
+// records of its tracks and their artists, creating the ones that don't
+// exist yet. Tracks are updated in batched store transactions, each track
+// together with the records merged into it, so sources scraped at the same
+// time don't lose each other's appearances. Updating with the same snapshot
+// again only changes UpdatedAt.
+func Update(ctx context.Context, st store.Store, collection, date string, tracks []fs.Track) error {
+	// A snapshot may list a track twice; its best rank counts
+	byID := make(map[string]fs.Track)
+	var ids []string
+	for _, track := range tracks {
+		id := track.TrackID
+		if id == "" {
+			id = ID(track)
+		}
+		prev, seen := byID[id]
+		if !seen {
+			ids = append(ids, id)
+		}
+		if !seen || track.Rank < prev.Rank {
+			byID[id] = track
+		}
+	}
+
+	now := time.Now()
+	merged := make(map[string]string) // Old track ID -> ID it was merged into
+	var updated []fs.Track
+	for start := 0; start < len(ids); start += trackBatchSize {
+		batch := ids[start:min(start+trackBatchSize, len(ids))]
+		targets, moved, err := updateTracks(ctx, st, batch, byID, collection, date, now)
+		if err != nil {
+			return err
+		}
+
+		maps.Copy(merged, moved)
+		for _, id := range batch {
+			track := byID[id]
+			track.TrackID = targets[id]
+			updated = append(updated, track)
+		}
+	}
+	return updateArtists(ctx, st, collection, date, updated, merged, now)
+}
+
+// trackBatchSize is how many tracks share a transaction. A track writes its
+// record and at most two merged ones, well within Firestore's 500 writes
+// per transaction.
+const trackBatchSize = 100
+
+// errMerged reports that a record was merged into another while updating it
+var errMerged = errors.New("record was merged")
+
+// updateTracks adds the tracks of ids to their live records in one
+// transaction, merging the records under each track's other IDs into them
+// in that same transaction. It returns the ID of each track's live record,
+// and the IDs merged into another record.
+func updateTracks(ctx context.Context, st store.Store, ids []string, byID map[string]fs.Track, collection, date string, now time.Time) (map[string]string, map[string]string, error) {
+	for attempt := 0; ; attempt++ {
+		live := make(map[string]string, len(ids))
+		var all []string
+		for _, id := range ids {
+			target, err := liveTrackID(ctx, st, id)
+			if err != nil {
+				return nil, nil, fmt.Errorf("reading track %s: %w", id, err)
+			}
+			live[id] = target
+			all = append(all, target)
+			all = append(all, otherIDs(trackIDs(byID[id]), id, target)...)
+		}
+
+		var targets, merged map[string]string
+		err := st.UpdateTracks(ctx, otherIDs(all), func(records map[string]*fs.CanonicalTrack) error {
+			targets, merged = make(map[string]string), make(map[string]string)
+			for _, id := range ids {
+				track := byID[id]
+				target, err := followMerged(records, live[id])
+				if err != nil {
+					return err
+				}
+
+				record, ok := records[target]
+				if !ok {
+					record = &fs.CanonicalTrack{ID: target}
+					records[target] = record
+				}
+				for _, alias := range otherIDs(trackIDs(track), id, target) {
+					old, ok := records[alias]
+					if ok && old.MergedInto != "" {
+						continue
+					}
+					if ok {
+						mergeRecord(record, *old)
+						merged[alias] = target
+					}
+					records[alias] = &fs.CanonicalTrack{ID: alias, MergedInto: target, UpdatedAt: now}
+				}
+				merge(record, track, collection, date)
+				record.UpdatedAt = now
+
+				targets[id] = target
+				if id != target {
+					merged[id] = target
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, errMerged) && attempt < maxMerges {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("saving tracks: %w", err)
+		}
+		return targets, merged, nil
+	}
+}
+
+// followMerged follows records merged earlier in the transaction to the live
+// one. A record merged into one the transaction didn't read means another
+// source merged it since it was looked up.
+func followMerged(records map[string]*fs.CanonicalTrack, id string) (string, error) {
+	for i := 0; ; i++ {
+		record, ok := records[id]
+		if !ok || record.MergedInto == "" {
+			return id, nil
+		}
+		if _, ok := records[record.MergedInto]; !ok || i == maxMerges {
+			return "", errMerged
+		}
+		id = record.MergedInto
+	}
+}
+
+// liveTrackID follows the merged record of id, if any, to the ID of the
+// record it was merged into. Merged records never change, so this needs no
+// transaction.
+func liveTrackID(ctx context.Context, st store.Store, id string) (string, error) {
+	track, err := GetTrack(ctx, st, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return id, nil
+	}
+	return track.ID, err
+}
+
+// otherIDs returns ids without the ones in exclude
+func otherIDs(ids []string, exclude ...string) []string {
+	var others []string
+	for _, id := range ids {
+		if !slices.Contains(exclude, id) && !slices.Contains(others, id) {
+			others = append(others, id)
+		}
+	}
+	return others
+}
+
+// merge adds an appearance of track to its record and fills in metadata
+// the record is missing
+func merge(record *fs.CanonicalTrack, track fs.Track, collection, date string) {
+	mergeRecord(record, fs.CanonicalTrack{
+		Artist:      track.Artist,
+		Title:       track.Title,
+		MBID:        track.MBID,
+		ISRC:        track.ISRC,
+		SpotifyID:   track.SpotifyID,
+		Thumb:       track.Thumb,
+		Artists:     track.Artists,
+		Appearances: []fs.TrackAppearance{{Source: collection, Date: date, Rank: track.Rank}},
+	})
+}
+
+// mergeRecord adds the appearances of other to record and fills in
+// metadata the record is missing
+func mergeRecord(record *fs.CanonicalTrack, other fs.CanonicalTrack) {
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	fill(&record.Artist, other.Artist)
+	fill(&record.Title, other.Title)
+	fill(&record.MBID, other.MBID)
+	fill(&record.ISRC, other.ISRC)
+	fill(&record.SpotifyID, other.SpotifyID)
+	fill(&record.Thumb, other.Thumb)
+	if len(record.Artists) == 0 {
+		record.Artists = other.Artists
+	} else {
+		FillCredits(record.Artists, other.Artists)
+	}
+
+	for _, appearance := range other.Appearances {
+		replaced := false
+		for i, a := range record.Appearances {
+			if a.Source == appearance.Source && a.Date == appearance.Date {
+				record.Appearances[i] = appearance
+				replaced = true
+			}
+		}
+		if !replaced {
+			record.Appearances = append(record.Appearances, appearance)
+		}
+	}
+	if len(record.Appearances) == 0 {
+		return
+	}
+	sort.Slice(record.Appearances, func(i, j int) bool {
+		a, b := record.Appearances[i], record.Appearances[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		return a.Source < b.Source
+	})
+
+	sources := make(map[string]bool)
+	record.Sources = record.Sources[:0]
+	for _, a := range record.Appearances {
+		if !sources[a.Source] {
+			sources[a.Source] = true
+			record.Sources = append(record.Sources, a.Source)
+		}
+	}
+	sort.Strings(record.Sources)
+
+	record.FirstSeen = record.Appearances[0].Date
+	record.LastSeen = record.Appearances[len(record.Appearances)-1].Date
+}
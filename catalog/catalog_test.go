@@ -0,0 +1,175 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	fs "melodex/firestore"
+	"melodex/store"
+)
+
+func TestID(t *testing.T) {
+	saturn := fs.Track{Artist: "SZA", Title: "Saturn", ISRC: "USRC12400001", MBID: "a1b2"}
+
+	id := ID(saturn)
+	if len(id) != 18 || id[:2] != "mx" {
+		t.Errorf("Expected an mx-prefixed ID, got %q", id)
+	}
+	if other := ID(fs.Track{Artist: "Sza", Title: "Saturn (Live)", ISRC: " usrc12400001 "}); other != id {
+		t.Errorf("Expected the ISRC to decide the ID, got %q and %q", id, other)
+	}
+	if ID(fs.Track{Artist: "SZA", Title: "Saturn", MBID: "a1b2"}) == id {
+		t.Error("Expected IDs from different identifiers to differ")
+	}
+	if ID(fs.Track{Artist: "SZA feat. Kendrick Lamar", Title: "Saturn"}) != ID(fs.Track{Artist: "SZA", Title: "Saturn"}) {
+		t.Error("Expected tracks without identifiers to share the ID of their normalized key")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+	saturn := fs.Track{Rank: 3, Artist: "SZA", Title: "Saturn", ISRC: "USRC12400001"}
+	tracks := []fs.Track{saturn, {Rank: 1, Artist: "Future", Title: "Type Shit"}}
+	Assign(tracks)
+
+	if err := Update(ctx, st, "billboard", "2024-02-04", tracks); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// A second source adds metadata, and the same snapshot again changes nothing
+	hnhh := []fs.Track{{Rank: 2, Artist: "SZA", Title: "Saturn", ISRC: "USRC12400001", Thumb: "abc"}}
+	if err := Update(ctx, st, "hnhh", "2024-02-03", hnhh); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := Update(ctx, st, "billboard", "2024-02-04", tracks); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	got, err := st.GetTrack(ctx, tracks[0].TrackID)
+	if err != nil {
+		t.Fatalf("GetTrack: %v", err)
+	}
+	want := []fs.TrackAppearance{
+		{Source: "hnhh", Date: "2024-02-03", Rank: 2},
+		{Source: "billboard", Date: "2024-02-04", Rank: 3},
+	}
+	if !reflect.DeepEqual(got.Appearances, want) {
+		t.Errorf("Unexpected appearances %+v", got.Appearances)
+	}
+	if !reflect.DeepEqual(got.Sources, []string{"billboard", "hnhh"}) || got.FirstSeen != "2024-02-03" || got.LastSeen != "2024-02-04" {
+		t.Errorf("Unexpected sources or dates %+v", got)
+	}
+	if got.Thumb != "abc" || got.ISRC != "USRC12400001" {
+		t.Errorf("Expected metadata merged from every source, got %+v", got)
+	}
+
+	if _, err := st.GetTrack(ctx, ID(fs.Track{Artist: "Future", Title: "Type Shit"})); err != nil {
+		t.Errorf("Expected a record for the track without identifiers: %v", err)
+	}
+}
+
+func TestUpdate_MergesOnBetterIdentifier(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+	plain := []fs.Track{{Rank: 4, Artist: "SZA", Title: "Saturn"}}
+	Assign(plain)
+	if err := Update(ctx, st, "billboard", "2024-02-03", plain); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	enriched := []fs.Track{{Rank: 2, Artist: "SZA", Title: "Saturn", ISRC: "USRC12400001"}}
+	Assign(enriched)
+	if err := Update(ctx, st, "hnhh", "2024-02-04", enriched); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	// Losing the identifier again still finds the merged record
+	if err := Update(ctx, st, "reddit_fresh", "2024-02-05", plain); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	got, err := GetTrack(ctx, st, plain[0].TrackID)
+	if err != nil {
+		t.Fatalf("GetTrack: %v", err)
+	}
+	if got.ID != enriched[0].TrackID || got.ISRC != "USRC12400001" {
+		t.Errorf("Expected the old ID to lead to the ISRC record, got %+v", got)
+	}
+	if len(got.Appearances) != 3 || got.FirstSeen != "2024-02-03" || got.LastSeen != "2024-02-05" {
+		t.Errorf("Expected every appearance in one record, got %+v", got.Appearances)
+	}
+
+	old, _ := st.GetTrack(ctx, plain[0].TrackID)
+	if old.MergedInto != got.ID || len(old.Appearances) != 0 {
+		t.Errorf("Expected the old record to only point to the new one, got %+v", old)
+	}
+}
+
+// countingStore counts track transactions
+type countingStore struct {
+	store.Store
+	updates int
+}
+
+func (s *countingStore) UpdateTracks(ctx context.Context, ids []string, fn func(map[string]*fs.CanonicalTrack) error) error {
+	s.updates++
+	return s.Store.UpdateTracks(ctx, ids, fn)
+}
+
+func TestUpdate_BatchesTracks(t *testing.T) {
+	ctx := context.Background()
+	st := &countingStore{Store: store.NewLocal(t.TempDir())}
+
+	var tracks []fs.Track
+	for i := 1; i <= trackBatchSize+50; i++ {
+		tracks = append(tracks, fs.Track{Rank: i, Artist: "Artist", Title: fmt.Sprintf("Song %d", i)})
+	}
+	// The same track with and without its ISRC, merged within one batch
+	tracks = append(tracks,
+		fs.Track{Rank: 200, Artist: "SZA", Title: "Saturn"},
+		fs.Track{Rank: 201, Artist: "SZA", Title: "Saturn", ISRC: "USRC12400001"},
+	)
+	Assign(tracks)
+	if err := Update(ctx, st, "billboard", "2024-02-04", tracks); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if st.updates != 2 {
+		t.Errorf("Expected two transactions for %d tracks, got %d", len(tracks), st.updates)
+	}
+	got, err := GetTrack(ctx, st, tracks[len(tracks)-2].TrackID)
+	if err != nil || got.ID != tracks[len(tracks)-1].TrackID || len(got.Appearances) != 1 {
+		t.Errorf("Expected the plain record merged into the ISRC record, got %+v, %v", got, err)
+	}
+}
+
+func TestUpdate_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+	sources := []string{"billboard", "hnhh", "reddit_fresh", "pitchfork_bnm", "spotify_new_releases"}
+
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source string) {
+			defer wg.Done()
+			tracks := []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn", Artists: []fs.ArtistCredit{{Name: "SZA", Role: fs.RolePrimary}}}}
+			Assign(tracks)
+			if err := Update(ctx, st, source, "2024-02-04", tracks); err != nil {
+				t.Errorf("Update: %v", err)
+			}
+		}(source)
+	}
+	wg.Wait()
+
+	track, err := GetTrack(ctx, st, ID(fs.Track{Artist: "SZA", Title: "Saturn"}))
+	if err != nil || len(track.Appearances) != len(sources) {
+		t.Errorf("Expected an appearance from every source, got %+v, %v", track, err)
+	}
+	artist, err := GetArtist(ctx, st, ArtistID(fs.ArtistCredit{Name: "SZA"}))
+	if err != nil || len(artist.Sources) != len(sources) {
+		t.Errorf("Expected the artist to list every source, got %+v, %v", artist, err)
+	}
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
@@ -9,6 +12,53 @@ import (
 type Config struct {
 	SpotifyID     string
 	SpotifySecret string
+
+	// Storage backend: "firestore" or "local" (JSON files under DataDir)
+	Store              string `default:"firestore"`
+	DataDir            string `envconfig:"DATA_DIR" default:"data"`
+	FirestoreProjectID string `envconfig:"FIRESTORE_PROJECT_ID" default:"beatbrain-dev"`
+
+	// In-process scheduler, off by default as it only guards against
+	// overlapping runs within one instance. Enable it on a single instance.
+	// Schedules overrides the cadence of a source by collection, e.g.
+	// "billboard=0 6 * * 2;reddit_fresh=off"
+	Scheduler      bool          `default:"false"`
+	Schedules      Schedules     `envconfig:"SCHEDULES"`
+	ScheduleJitter time.Duration `envconfig:"SCHEDULE_JITTER" default:"1m"`
+
+	// Scoring profiles, from a YAML file or inline YAML. ScoringProfile is
+	// used for the discovery feed and requests without ?profile=
+	ScoringProfiles ScoringProfiles `envconfig:"SCORING_PROFILES"`
+	ScoringProfile  string          `envconfig:"SCORING_PROFILE" default:"default"`
+
+	// How long source snapshots and discovery feeds are kept. Chart history
+	// (streaks, peaks, weeks on chart) only covers the kept snapshots
+	HistoryRetention time.Duration `envconfig:"HISTORY_RETENTION" default:"168h"`
+
+	// How long a song whose lookups found no or partial metadata is served
+	// from the enrichment cache before it is looked up again
+	EnrichmentRetryAfter time.Duration `envconfig:"ENRICHMENT_RETRY_AFTER" default:"168h"`
+}
+
+// Schedules maps a source collection to a cron expression, or "off".
+// Entries are separated by semicolons, since cron expressions may contain commas.
+type Schedules map[string]string
+
+func (s *Schedules) Decode(value string) error {
+	schedules := make(Schedules)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		source, cadence, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid schedule %q, expected source=cron", entry)
+		}
+		schedules[strings.TrimSpace(source)] = strings.TrimSpace(cadence)
+	}
+	*s = schedules
+	return nil
 }
 
 func ProvideConfig() Config {
@@ -17,6 +67,9 @@ func ProvideConfig() Config {
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	if _, ok := cfg.ScoringProfiles.Get(cfg.ScoringProfile); !ok {
+		log.Fatalf("Unknown scoring profile: %s", cfg.ScoringProfile)
+	}
 	return cfg
 }
 
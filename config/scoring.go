@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultScoringProfileName is the profile used when none is selected.
+const DefaultScoringProfileName = "default"
+
+// ScoringProfile holds the tunables of the discovery score:
+//
+//	score = weight × normalized rank + freshness bonus + cross-source bonus
+type ScoringProfile struct {
+	Name string `yaml:"-" json:"name"`
+
+	// Weights by collection. Sources without one use their registered
+	// weight, unknown sources DefaultWeight.
+	Weights       map[string]float64 `yaml:"weights" json:"weights,omitempty"`
+	DefaultWeight float64            `yaml:"defaultWeight" json:"defaultWeight"`
+	RankDepth     int                `yaml:"rankDepth" json:"rankDepth"` // Ranks past this normalize to 0
+
+	// Freshness bonus, at most FreshnessBonus. It decays linearly from
+	// FreshHours to StaleHours after a track was first stored.
+	FreshnessBonus float64 `yaml:"freshnessBonus" json:"freshnessBonus"`
+	FreshHours     float64 `yaml:"freshHours" json:"freshHours"`
+	StaleHours     float64 `yaml:"staleHours" json:"staleHours"`
+
+	// Bonus per source beyond the first, up to CrossSourceCap
+	CrossSourceBonus float64 `yaml:"crossSourceBonus" json:"crossSourceBonus"`
+	CrossSourceCap   float64 `yaml:"crossSourceCap" json:"crossSourceCap"`
+}
+
+// DefaultScoringProfile returns the built-in profile. Profiles loaded from
+// config start from it, so they only need the values they change.
+func DefaultScoringProfile() ScoringProfile {
+	return ScoringProfile{
+		Name:             DefaultScoringProfileName,
+		DefaultWeight:    0.3,
+		RankDepth:        100,
+		FreshnessBonus:   0.2,
+		FreshHours:       24,
+		StaleHours:       168,
+		CrossSourceBonus: 0.1,
+		CrossSourceCap:   0.3,
+	}
+}
+
+func (p *ScoringProfile) UnmarshalYAML(node *yaml.Node) error {
+	type plain ScoringProfile
+	profile := plain(DefaultScoringProfile())
+	if err := node.Decode(&profile); err != nil {
+		return err
+	}
+	*p = ScoringProfile(profile)
+	return nil
+}
+
+// Validate reports values that would make scores meaningless
+func (p ScoringProfile) Validate() error {
+	var errs []error
+	if p.RankDepth <= 0 {
+		errs = append(errs, errors.New("rankDepth must be positive"))
+	}
+	if p.StaleHours <= p.FreshHours {
+		errs = append(errs, errors.New("staleHours must be after freshHours"))
+	}
+	for source, weight := range p.Weights {
+		if weight < 0 {
+			errs = append(errs, fmt.Errorf("weight of %s must not be negative", source))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("scoring profile %s: %w", p.Name, err)
+	}
+	return nil
+}
+
+// ScoringProfiles maps profile names to profiles. It decodes from the path
+// of a YAML file, or from inline YAML, e.g.
+//
+//	editorial: {weights: {pitchfork_bnm: 1.0}, crossSourceCap: 0.5}
+type ScoringProfiles map[string]ScoringProfile
+
+func (p *ScoringProfiles) Decode(value string) error {
+	data := []byte(value)
+	if _, err := os.Stat(value); err == nil {
+		if data, err = os.ReadFile(value); err != nil {
+			return err
+		}
+	}
+
+	profiles := make(ScoringProfiles)
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("invalid scoring profiles: %w", err)
+	}
+	for name, profile := range profiles {
+		profile.Name = name
+		if err := profile.Validate(); err != nil {
+			return err
+		}
+		profiles[name] = profile
+	}
+	*p = profiles
+	return nil
+}
+
+// Get returns the profile called name, or the default profile for "".
+// The built-in profile is used for "default" unless config overrides it.
+func (p ScoringProfiles) Get(name string) (ScoringProfile, bool) {
+	if name == "" {
+		name = DefaultScoringProfileName
+	}
+	if profile, ok := p[name]; ok {
+		return profile, true
+	}
+	if name == DefaultScoringProfileName {
+		return DefaultScoringProfile(), true
+	}
+	return ScoringProfile{}, false
+}
+
+// All returns every profile, including the built-in one, sorted by name
+func (p ScoringProfiles) All() []ScoringProfile {
+	profiles := make([]ScoringProfile, 0, len(p)+1)
+	if _, ok := p[DefaultScoringProfileName]; !ok {
+		profiles = append(profiles, DefaultScoringProfile())
+	}
+	for _, profile := range p {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles
+}
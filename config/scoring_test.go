@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScoringProfiles_DecodeInheritsDefaults(t *testing.T) {
+	var profiles ScoringProfiles
+	if err := profiles.Decode("editorial: {weights: {pitchfork_bnm: 1.0}, crossSourceCap: 0.5}"); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	p, ok := profiles.Get("editorial")
+	if !ok {
+		t.Fatal("Expected editorial profile")
+	}
+	if p.Name != "editorial" || p.Weights["pitchfork_bnm"] != 1.0 || p.CrossSourceCap != 0.5 {
+		t.Errorf("Unexpected profile %+v", p)
+	}
+
+	def := DefaultScoringProfile()
+	if p.RankDepth != def.RankDepth || p.FreshnessBonus != def.FreshnessBonus || p.StaleHours != def.StaleHours {
+		t.Errorf("Expected unset values to come from the default profile, got %+v", p)
+	}
+}
+
+func TestScoringProfiles_DecodeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	if err := os.WriteFile(path, []byte("charts:\n  rankDepth: 50\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var profiles ScoringProfiles
+	if err := profiles.Decode(path); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if p, _ := profiles.Get("charts"); p.RankDepth != 50 {
+		t.Errorf("Expected rankDepth 50, got %d", p.RankDepth)
+	}
+}
+
+func TestScoringProfiles_DecodeRejectsInvalid(t *testing.T) {
+	for _, value := range []string{
+		"bad: {rankDepth: 0}",
+		"bad: {freshHours: 200}",
+		"bad: {weights: {hnhh: -1}}",
+		"bad: [1, 2]",
+	} {
+		var profiles ScoringProfiles
+		if err := profiles.Decode(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestScoringProfiles_Get(t *testing.T) {
+	var profiles ScoringProfiles
+	if p, ok := profiles.Get(""); !ok || p.Name != DefaultScoringProfileName {
+		t.Errorf("Expected the built-in default profile, got %+v", p)
+	}
+	if _, ok := profiles.Get("missing"); ok {
+		t.Error("Expected unknown profile to be missing")
+	}
+	if all := profiles.All(); len(all) != 1 || all[0].Name != DefaultScoringProfileName {
+		t.Errorf("Expected only the default profile, got %+v", all)
+	}
+}
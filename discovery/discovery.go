@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"melodex/config"
+	fs "melodex/firestore"
+	"melodex/history"
+	"melodex/scoring"
+	"melodex/store"
+)
+
+// Build ranks the snapshots of collections on date into a discovery feed
+// scored with profile, with WeeksOnChart from each source's chart history
+// over the retention window before date.
+// Collections without a snapshot that day are skipped. Freshness is measured
+// at the end of date rather than now, so building the same date twice gives
+// the same feed.
+func Build(ctx context.Context, st store.Store, date string, collections []string, profile config.ScoringProfile, retention time.Duration) (fs.Discovery, error) {
+	feed := fs.Discovery{
+		Date:        date,
+		Profile:     profile.Name,
+		Sources:     []string{},
+		Tracks:      []fs.DiscoveryTrack{},
+		GeneratedAt: time.Now(),
+	}
+
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return feed, fmt.Errorf("invalid date %q: %w", date, err)
+	}
+	now := day.Add(24 * time.Hour)
+
+	var tracks []scoring.ScoredTrack
+	for _, collection := range collections {
+		chart, err := history.Load(ctx, st, collection, history.From(date, retention), date)
+		if err != nil {
+			return feed, fmt.Errorf("%s: %w", collection, err)
+		}
+		snapshot, ok := chart.Snapshot(date)
+		if !ok {
+			continue
+		}
+
+		feed.Sources = append(feed.Sources, collection)
+		for _, track := range snapshot {
+			scored := scoring.FromTrack(track, collection)
+			if h, ok := chart.Lookup(track); ok {
+				scored.WeeksOnChart = h.WeeksOnChart
+			}
+			tracks = append(tracks, scored)
+		}
+	}
+
+	for _, track := range scoring.RankAndDeduplicateWith(tracks, profile, now) {
+		feed.Tracks = append(feed.Tracks, fs.DiscoveryTrack{
+			Artist:         track.Artist,
+			Title:          track.Title,
+			MBID:           track.MBID,
+			ISRC:           track.ISRC,
+			SpotifyID:      track.SpotifyID,
+			Thumb:          track.Thumb,
+			Source:         track.Source,
+			Rank:           track.Rank,
+			CreatedAt:      track.CreatedAt,
+			Score:          track.Score,
+			WeeksOnChart:   track.WeeksOnChart,
+			SourceCount:    track.SourceCount,
+			ScoreBreakdown: track.ScoreBreakdown,
+
+			PossibleDuplicates: track.PossibleDuplicates,
+		})
+	}
+	return feed, nil
+}
+
+// Rebuild builds the feed of date and saves it to the discovery collection,
+// replacing any previous feed of that date.
+func Rebuild(ctx context.Context, st store.Store, date string, collections []string, profile config.ScoringProfile, retention time.Duration) (fs.Discovery, error) {
+	feed, err := Build(ctx, st, date, collections, profile, retention)
+	if err != nil {
+		return feed, err
+	}
+	if err := st.SaveDiscovery(ctx, feed); err != nil {
+		return feed, fmt.Errorf("saving discovery feed: %w", err)
+	}
+	return feed, nil
+}
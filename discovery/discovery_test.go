@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"melodex/config"
+	fs "melodex/firestore"
+	"melodex/store"
+)
+
+func TestRebuild_IsDeterministic(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+	created := time.Date(2024, 2, 4, 12, 0, 0, 0, time.UTC)
+	st.SaveSnapshot(ctx, "billboard", "2024-02-04", []fs.Track{
+		{Rank: 1, Artist: "SZA", Title: "Saturn", Source: "billboard", CreatedAt: created},
+		{Rank: 2, Artist: "Tate McRae", Title: "greedy", Source: "billboard", CreatedAt: created},
+	})
+	st.SaveSnapshot(ctx, "hnhh", "2024-02-04", []fs.Track{
+		{Rank: 1, Artist: "SZA", Title: "Saturn", Source: "hnhh", CreatedAt: created},
+		{Rank: 1, Artist: "Future", Title: "Type Shit", Source: "hnhh", CreatedAt: created},
+	})
+	collections := []string{"billboard", "hnhh", "reddit_fresh"}
+
+	profile := config.DefaultScoringProfile()
+
+	first, err := Rebuild(ctx, st, "2024-02-04", collections, profile, fs.DefaultTTL)
+	if err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	if !reflect.DeepEqual(first.Sources, []string{"billboard", "hnhh"}) {
+		t.Errorf("Expected collections without a snapshot to be skipped, got %v", first.Sources)
+	}
+	if len(first.Tracks) != 3 {
+		t.Fatalf("Expected 3 deduplicated tracks, got %+v", first.Tracks)
+	}
+	for _, track := range first.Tracks {
+		b := track.ScoreBreakdown
+		if b == nil || b.BaseScore+b.FreshnessBonus+b.CrossSourceBonus != track.Score {
+			t.Errorf("Expected the breakdown to add up to the score of %s, got %+v", track.Title, b)
+		}
+		if b != nil && b.FreshnessBonus != profile.FreshnessBonus {
+			t.Errorf("Expected freshness to be measured at the end of the feed date, got %v", b.FreshnessBonus)
+		}
+	}
+
+	second, err := Rebuild(ctx, st, "2024-02-04", collections, profile, fs.DefaultTTL)
+	if err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	if !reflect.DeepEqual(first.Tracks, second.Tracks) {
+		t.Errorf("Expected rebuilding to give the same feed\nfirst:  %+v\nsecond: %+v", first.Tracks, second.Tracks)
+	}
+
+	saved, err := st.GetDiscovery(ctx, "2024-02-04")
+	if err != nil || len(saved.Tracks) != 3 {
+		t.Errorf("Expected the feed to be saved, got %+v, %v", saved, err)
+	}
+}
+
+func TestBuild_RejectsInvalidDate(t *testing.T) {
+	if _, err := Build(context.Background(), store.NewLocal(t.TempDir()), "today", nil, config.DefaultScoringProfile(), fs.DefaultTTL); err == nil {
+		t.Error("Expected an error for an invalid date")
+	}
+}
+
+func TestBuild_FillsWeeksOnChart(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+	for _, date := range []string{"2024-01-23", "2024-01-30", "2024-02-06"} {
+		st.SaveSnapshot(ctx, "billboard", date, []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn"}})
+	}
+
+	feed, err := Build(ctx, st, "2024-01-30", []string{"billboard"}, config.DefaultScoringProfile(), fs.DefaultTTL)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if len(feed.Tracks) != 1 || feed.Tracks[0].WeeksOnChart != 2 {
+		t.Errorf("Expected two weeks on chart as of 2024-01-30, got %+v", feed.Tracks)
+	}
+}
@@ -0,0 +1,145 @@
+package enrichment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"melodex/catalog"
+	fs "melodex/firestore"
+	"melodex/scoring"
+	"melodex/store"
+)
+
+// featuredInTitle matches a featuring credit in a title, e.g. "(feat. Future)"
+var featuredInTitle = regexp.MustCompile(`(?i)\s*[(\[]?\b(?:feat\.?|ft\.?|featuring)\s[^)\]]*[)\]]?`)
+
+// Cache is the long-lived store of lookup results every source reads from.
+// store.Store implements it.
+type Cache interface {
+	GetEnrichment(ctx context.Context, id string) (fs.EnrichmentEntry, error)
+	SaveEnrichment(ctx context.Context, entry fs.EnrichmentEntry) error
+}
+
+// songKey is the cache key of a song: its primary artist and title,
+// normalized. Unlike scoring.TrackKey it keeps version parentheticals, so a
+// remix never gets the metadata of the original.
+func songKey(artist, title string) string {
+	if credits := catalog.ParseArtists(artist); len(credits) > 0 {
+		artist = credits[0].Name
+	}
+	title = featuredInTitle.ReplaceAllString(title, "")
+	return "track:" + scoring.NormalizeName(artist) + " - " + scoring.NormalizeName(title)
+}
+
+// isrcKey is the cache key of the MusicBrainz ID of an ISRC
+func isrcKey(isrc string) string {
+	return "isrc:" + strings.ToUpper(strings.TrimSpace(isrc))
+}
+
+// entryID returns the document ID of a key. Keys are hashed, since artists
+// and titles may contain characters document IDs can't.
+func entryID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// lookup returns the cache entry of key, if there is one
+func (e *Enricher) lookup(ctx context.Context, key string) (fs.EnrichmentEntry, bool) {
+	if e.cache == nil {
+		return fs.EnrichmentEntry{}, false
+	}
+	entry, err := e.cache.GetEnrichment(ctx, entryID(key))
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Error reading enrichment cache entry %s: %v", key, err)
+		}
+		return fs.EnrichmentEntry{}, false
+	}
+	return entry, true
+}
+
+// save writes entry under its key. A failed write only costs a lookup later.
+func (e *Enricher) save(ctx context.Context, entry fs.EnrichmentEntry) {
+	entry.ID = entryID(entry.Key)
+	entry.UpdatedAt = time.Now()
+	if err := e.cache.SaveEnrichment(ctx, entry); err != nil {
+		log.Printf("Error saving enrichment cache entry %s: %v", entry.Key, err)
+	}
+}
+
+// cachedMBID fills in the MusicBrainz ID of an item whose ISRC is cached
+func (e *Enricher) cachedMBID(ctx context.Context, item *Item) {
+	if item.Track.ISRC == "" || item.Track.MBID != "" || item.checkedISRC == item.Track.ISRC {
+		return
+	}
+	item.checkedISRC = item.Track.ISRC
+	if entry, found := e.lookup(ctx, isrcKey(item.Track.ISRC)); found && entry.MBID != "" {
+		item.Track.MBID = entry.MBID
+		item.cachedMBID = true
+	}
+}
+
+// remember caches what the lookups of an item found. Nothing is cached
+// under the song when a stage failed for any other reason than finding no
+// match, so an outage isn't remembered as missing metadata.
+func (e *Enricher) remember(ctx context.Context, item *Item, errs map[string]error) {
+	if e.cache == nil || ctx.Err() != nil {
+		return
+	}
+
+	track := item.Track
+	if track.ISRC != "" && track.MBID != "" && !item.cachedMBID {
+		e.save(ctx, fs.EnrichmentEntry{Key: isrcKey(track.ISRC), ISRC: track.ISRC, MBID: track.MBID})
+	}
+
+	for _, err := range errs {
+		if !errors.Is(err, ErrNoMatch) {
+			return
+		}
+	}
+	entry := fs.EnrichmentEntry{
+		Key:       songKey(item.Song.Artist, item.Song.Title),
+		ISRC:      track.ISRC,
+		SpotifyID: track.SpotifyID,
+		MBID:      track.MBID,
+		Thumb:     track.Thumb,
+		Artists:   track.Artists,
+	}
+	if !complete(entry) {
+		entry.RetryAfter = time.Now().Add(e.retryAfter)
+	}
+	e.save(ctx, entry)
+}
+
+// complete reports whether every lookup found its part of an entry
+func complete(entry fs.EnrichmentEntry) bool {
+	return entry.SpotifyID != "" && entry.MBID != ""
+}
+
+// fresh reports whether entry can be used without looking the song up again
+func fresh(entry fs.EnrichmentEntry, now time.Time) bool {
+	return complete(entry) || now.Before(entry.RetryAfter)
+}
+
+// fill copies the metadata of entry that track is missing
+func fill(track *fs.Track, entry fs.EnrichmentEntry) {
+	set := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	set(&track.ISRC, entry.ISRC)
+	set(&track.SpotifyID, entry.SpotifyID)
+	set(&track.MBID, entry.MBID)
+	set(&track.Thumb, entry.Thumb)
+	if len(entry.Artists) > 0 {
+		track.Artists = slices.Clone(entry.Artists)
+	}
+}
@@ -0,0 +1,36 @@
+package enrichment
+
+import (
+	"context"
+	"strings"
+)
+
+// thumbSize is the width and height of the album image we keep
+const thumbSize = 300
+
+// spotifyImagePrefix is stripped so only the image hash is stored
+const spotifyImagePrefix = "https://i.scdn.co/image/"
+
+// CoverArtStage picks the album thumbnail from the matched Spotify track.
+type CoverArtStage struct{}
+
+// NewCoverArtStage creates a thumbnail selection stage
+func NewCoverArtStage() *CoverArtStage {
+	return &CoverArtStage{}
+}
+
+func (s *CoverArtStage) Name() string { return StageCoverArt }
+
+func (s *CoverArtStage) Enrich(ctx context.Context, item *Item) error {
+	if item.Track.Thumb != "" || item.SpotifyTrack == nil {
+		return nil
+	}
+
+	for _, image := range item.SpotifyTrack.Album.Images {
+		if image.Height == thumbSize && image.Width == thumbSize {
+			item.Track.Thumb = strings.TrimPrefix(image.URL, spotifyImagePrefix)
+			return nil
+		}
+	}
+	return nil
+}
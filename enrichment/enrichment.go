@@ -0,0 +1,225 @@
+package enrichment
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	spotify "github.com/zmb3/spotify/v2"
+
+	"melodex/catalog"
+	"melodex/config"
+	fs "melodex/firestore"
+	mb "melodex/musicbrainz"
+	spot "melodex/spotify"
+	"melodex/store"
+)
+
+// Item is a song moving through the enrichment stages.
+type Item struct {
+	Song  fs.Song
+	Track fs.Track
+
+	// SpotifyTrack is the matched Spotify track, set by the Spotify stage
+	SpotifyTrack *spotify.FullTrack
+
+	checkedISRC string // ISRC last looked up in the cache
+	cachedMBID  bool   // MBID came from the cache
+}
+
+// Stage adds metadata to an item. A failing stage is logged and the
+// remaining stages still run, so missing metadata never drops a track.
+type Stage interface {
+	Name() string
+	Enrich(ctx context.Context, item *Item) error
+}
+
+// Names of the default stages, as used in Stats.Misses
+const (
+	StageSpotify     = "spotify"
+	StageMusicBrainz = "musicbrainz"
+	StageCoverArt    = "coverart"
+)
+
+// maxStatsErrors caps Stats.Errors, so one broken stage can't bloat a run record
+const maxStatsErrors = 20
+
+// Stats counts what happened to the songs of one Enrich call.
+type Stats struct {
+	Enriched int            // Songs that went through the stages
+	Reused   int            // Songs matched against previous tracks
+	Cached   int            // Songs filled from the enrichment cache
+	Failed   int            // Enriched songs where at least one stage failed
+	Misses   map[string]int // Failures per stage name
+	Errors   []string       // The first stage errors, see maxStatsErrors
+}
+
+// clone copies the stats, so callers can keep them while enrichment goes on
+func (s Stats) clone() Stats {
+	s.Misses = maps.Clone(s.Misses)
+	s.Errors = slices.Clone(s.Errors)
+	return s
+}
+
+// ProgressFunc is called with the running totals after every song.
+type ProgressFunc func(Stats)
+
+// Enricher turns scraped songs into stored tracks.
+type Enricher struct {
+	stages []Stage
+
+	cache      Cache // Optional
+	retryAfter time.Duration
+}
+
+// New creates an Enricher that runs the stages in order. Stages pace
+// their own lookups, e.g. through the MusicBrainz rate limiter.
+func New(stages ...Stage) *Enricher {
+	return &Enricher{stages: stages}
+}
+
+// WithCache makes the Enricher read and write lookup results in cache.
+// Songs with missing metadata are looked up again after retryAfter.
+func (e *Enricher) WithCache(cache Cache, retryAfter time.Duration) *Enricher {
+	e.cache = cache
+	e.retryAfter = retryAfter
+	return e
+}
+
+// ProvideEnricher provides the default Spotify → MusicBrainz → cover art
+// pipeline, cached in the store
+func ProvideEnricher(sp *spot.SpotifyClient, mbc *mb.MusicbrainzClient, st store.Store, cfg config.Config) *Enricher {
+	return New(
+		NewSpotifyStage(sp),
+		NewMusicBrainzStage(mbc),
+		NewCoverArtStage(),
+	).WithCache(st, cfg.EnrichmentRetryAfter)
+}
+
+var Options = ProvideEnricher
+
+// Enrich converts songs into tracks for the given source. Tracks found in
+// previous (usually yesterday's snapshot) are reused, and songs in the
+// enrichment cache are filled from it, instead of looked up.
+// progress may be nil. If ctx is done, the tracks enriched so far are returned.
+func (e *Enricher) Enrich(ctx context.Context, source string, songs []fs.Song, previous []fs.Track, progress ProgressFunc) ([]fs.Track, Stats) {
+	stats := Stats{Misses: make(map[string]int)}
+	report := func() {
+		if progress != nil {
+			progress(stats.clone())
+		}
+	}
+
+	reuse := make(map[string]fs.Track, len(previous))
+	for _, track := range previous {
+		reuse[cacheKey(track.Artist, track.Title)] = track
+	}
+
+	tracks := make([]fs.Track, 0, len(songs))
+	for _, song := range songs {
+		if ctx.Err() != nil {
+			log.Printf("Enrichment of %s cancelled: %v", source, ctx.Err())
+			break
+		}
+
+		if existingTrack, found := reuse[cacheKey(song.Artist, song.Title)]; found {
+			// Reuse the stored metadata, but keep today's position
+			existingTrack.Rank = song.Rank
+			if len(existingTrack.Artists) == 0 {
+				existingTrack.Artists = songArtists(song)
+			}
+			tracks = append(tracks, existingTrack)
+			stats.Reused++
+			report()
+			log.Printf("Reused metadata for %s track: %s by %s", source, song.Title, song.Artist)
+			continue
+		}
+
+		entry, cached := e.lookup(ctx, songKey(song.Artist, song.Title))
+		if cached && fresh(entry, time.Now()) {
+			track := baseTrack(source, song)
+			fill(&track, entry)
+			tracks = append(tracks, track)
+			stats.Cached++
+			report()
+			log.Printf("Used cached metadata for %s track: %s by %s", source, song.Title, song.Artist)
+			continue
+		}
+
+		item := &Item{Song: song, Track: baseTrack(source, song)}
+		if cached {
+			// Only look up what the expired entry is missing
+			fill(&item.Track, entry)
+		}
+		errs := e.enrichItem(ctx, item)
+		e.remember(ctx, item, errs)
+		tracks = append(tracks, item.Track)
+		stats.Enriched++
+		if len(errs) > 0 {
+			stats.Failed++
+		}
+		for _, stage := range e.stages {
+			err, failed := errs[stage.Name()]
+			if !failed {
+				continue
+			}
+			stats.Misses[stage.Name()]++
+			if len(stats.Errors) < maxStatsErrors {
+				stats.Errors = append(stats.Errors, fmt.Sprintf("%s: %s by %s: %v", stage.Name(), song.Title, song.Artist, err))
+			}
+		}
+		report()
+		log.Printf("Added new %s track: %s by %s", source, song.Title, song.Artist)
+	}
+	return tracks, stats
+}
+
+// baseTrack is the track of a song before any lookup
+func baseTrack(source string, song fs.Song) fs.Track {
+	return fs.Track{
+		Rank:      song.Rank,
+		Artist:    song.Artist,
+		Title:     song.Title,
+		ISRC:      song.ISRC,
+		SpotifyID: song.SpotifyID,
+		Thumb:     song.Thumb,
+		Source:    source,
+		CreatedAt: time.Now(),
+		Artists:   songArtists(song),
+	}
+}
+
+// enrichItem runs every stage, returning the errors of failed stages by
+// name. A cached MusicBrainz ID is used as soon as the ISRC is known.
+func (e *Enricher) enrichItem(ctx context.Context, item *Item) map[string]error {
+	song := item.Song
+	var errs map[string]error
+	for _, stage := range e.stages {
+		e.cachedMBID(ctx, item)
+		if err := stage.Enrich(ctx, item); err != nil {
+			log.Printf("%s stage failed for %s by %s: %v", stage.Name(), song.Title, song.Artist, err)
+			if errs == nil {
+				errs = make(map[string]error)
+			}
+			errs[stage.Name()] = err
+		}
+	}
+	return errs
+}
+
+// songArtists returns the credits the source listed, or parses them from
+// the song's artist
+func songArtists(song fs.Song) []fs.ArtistCredit {
+	if len(song.Artists) > 0 {
+		return slices.Clone(song.Artists)
+	}
+	return catalog.ParseArtists(song.Artist)
+}
+
+// cacheKey is the key used to match a song against previously stored tracks
+func cacheKey(artist, title string) string {
+	return artist + " - " + title
+}
@@ -0,0 +1,207 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	spotify "github.com/zmb3/spotify/v2"
+
+	fs "melodex/firestore"
+	"melodex/store"
+)
+
+type fakeStage struct {
+	name  string
+	calls int
+	fn    func(item *Item) error
+}
+
+func (s *fakeStage) Name() string { return s.name }
+
+func (s *fakeStage) Enrich(ctx context.Context, item *Item) error {
+	s.calls++
+	return s.fn(item)
+}
+
+func TestEnrich_ReusesPreviousTracks(t *testing.T) {
+	stage := &fakeStage{name: "fake", fn: func(item *Item) error {
+		item.Track.MBID = "new-mbid"
+		return nil
+	}}
+	e := New(stage)
+
+	songs := []fs.Song{
+		{Rank: 1, Artist: "SZA", Title: "Saturn"},
+		{Rank: 2, Artist: "Drake", Title: "Nokia"},
+	}
+	previous := []fs.Track{
+		{Rank: 7, Artist: "SZA", Title: "Saturn", MBID: "old-mbid", Source: "billboard"},
+	}
+
+	tracks, stats := e.Enrich(context.Background(), "billboard", songs, previous, nil)
+
+	if len(tracks) != 2 {
+		t.Fatalf("Expected 2 tracks, got %d", len(tracks))
+	}
+	if stats.Reused != 1 || stats.Enriched != 1 {
+		t.Errorf("Expected 1 reused and 1 enriched, got %+v", stats)
+	}
+	if stage.calls != 1 {
+		t.Errorf("Expected stages to run once for the new song, ran %d times", stage.calls)
+	}
+	if tracks[0].MBID != "old-mbid" || tracks[0].Rank != 1 {
+		t.Errorf("Expected reused track with today's rank, got %+v", tracks[0])
+	}
+	if tracks[1].MBID != "new-mbid" || tracks[1].Source != "billboard" {
+		t.Errorf("Expected enriched track, got %+v", tracks[1])
+	}
+}
+
+func TestEnrich_FailingStageKeepsTrack(t *testing.T) {
+	failing := &fakeStage{name: "failing", fn: func(item *Item) error {
+		return errors.New("boom")
+	}}
+	after := &fakeStage{name: "after", fn: func(item *Item) error {
+		item.Track.Thumb = "thumb"
+		return nil
+	}}
+	e := New(failing, after)
+
+	tracks, stats := e.Enrich(context.Background(), "hnhh", []fs.Song{{Rank: 1, Artist: "A", Title: "B"}}, nil, nil)
+
+	if len(tracks) != 1 {
+		t.Fatalf("Expected the track to be kept, got %d tracks", len(tracks))
+	}
+	if stats.Failed != 1 || stats.Misses["failing"] != 1 || len(stats.Errors) != 1 {
+		t.Errorf("Expected the song to count as failed, got %+v", stats)
+	}
+	if tracks[0].Thumb != "thumb" {
+		t.Errorf("Expected later stages to run after a failure, got %+v", tracks[0])
+	}
+}
+
+func TestEnrich_StopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stage := &fakeStage{name: "cancelling", fn: func(item *Item) error {
+		cancel()
+		return nil
+	}}
+	e := New(stage)
+
+	songs := []fs.Song{
+		{Rank: 1, Artist: "SZA", Title: "Saturn"},
+		{Rank: 2, Artist: "Drake", Title: "Nokia"},
+	}
+	tracks, stats := e.Enrich(ctx, "billboard", songs, nil, nil)
+
+	if len(tracks) != 1 || stats.Enriched != 1 || stage.calls != 1 {
+		t.Errorf("Expected enrichment to stop after the first song, got %d tracks, %+v", len(tracks), stats)
+	}
+}
+
+func TestEnrich_CreditsArtists(t *testing.T) {
+	e := New(&fakeStage{name: "noop", fn: func(item *Item) error { return nil }})
+
+	tracks, _ := e.Enrich(context.Background(), "billboard", []fs.Song{{Rank: 1, Artist: "SZA Featuring Kendrick Lamar", Title: "Doves"}}, nil, nil)
+
+	want := []fs.ArtistCredit{{Name: "SZA", Role: fs.RolePrimary}, {Name: "Kendrick Lamar", Role: fs.RoleFeatured}}
+	if !reflect.DeepEqual(tracks[0].Artists, want) {
+		t.Errorf("Expected credits parsed from the artist, got %+v", tracks[0].Artists)
+	}
+}
+
+func TestSpotifyArtists(t *testing.T) {
+	parsed := []fs.ArtistCredit{{Name: "Travis Scott", Role: fs.RolePrimary}, {Name: "Drake", Role: fs.RoleFeatured, MBID: "drake-mbid"}}
+	artists := []spotify.SimpleArtist{{Name: "Travis Scott", ID: "travis"}, {Name: "Drake", ID: "drake"}, {Name: "Playboi Carti", ID: "carti"}}
+
+	got := spotifyArtists(parsed, artists)
+	want := []fs.ArtistCredit{
+		{Name: "Travis Scott", Role: fs.RolePrimary, SpotifyID: "travis"},
+		{Name: "Drake", Role: fs.RoleFeatured, SpotifyID: "drake", MBID: "drake-mbid"},
+		{Name: "Playboi Carti", Role: fs.RoleFeatured, SpotifyID: "carti"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected credits\ngot:  %+v\nwant: %+v", got, want)
+	}
+	if got := spotifyArtists(parsed, nil); !reflect.DeepEqual(got, parsed) {
+		t.Errorf("Expected the parsed credits without Spotify artists, got %+v", got)
+	}
+}
+
+func TestEnrich_CacheIsSharedBySources(t *testing.T) {
+	cache := store.NewLocal(t.TempDir())
+	stage := &fakeStage{name: "fake", fn: func(item *Item) error {
+		item.Track.ISRC, item.Track.SpotifyID, item.Track.MBID = "USRC12400001", "saturn", "saturn-mbid"
+		return nil
+	}}
+	e := New(stage).WithCache(cache, time.Hour)
+
+	e.Enrich(context.Background(), "billboard", []fs.Song{{Rank: 1, Artist: "SZA", Title: "Saturn"}}, nil, nil)
+	tracks, stats := e.Enrich(context.Background(), "hnhh", []fs.Song{{Rank: 4, Artist: "Sza", Title: "Saturn (feat. Nobody)"}}, nil, nil)
+
+	if stage.calls != 1 || stats.Cached != 1 || stats.Enriched != 0 {
+		t.Errorf("Expected the second source to use the cache, got %d lookups and %+v", stage.calls, stats)
+	}
+	if tracks[0].MBID != "saturn-mbid" || tracks[0].ISRC != "USRC12400001" || tracks[0].Rank != 4 || tracks[0].Source != "hnhh" {
+		t.Errorf("Expected cached metadata on today's track, got %+v", tracks[0])
+	}
+
+	// A version of the song is a different recording
+	e.Enrich(context.Background(), "hnhh", []fs.Song{{Rank: 1, Artist: "SZA", Title: "Saturn (Remix)"}}, nil, nil)
+	if stage.calls != 2 {
+		t.Errorf("Expected a remix to be looked up, got %d lookups", stage.calls)
+	}
+}
+
+func TestEnrich_CachesMissesUntilRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		retryAfter time.Duration
+		calls      int
+	}{
+		{"no match", ErrNoMatch, time.Hour, 1},
+		{"expired", ErrNoMatch, -time.Second, 2},
+		{"outage", errors.New("connection refused"), time.Hour, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage := &fakeStage{name: "fake", fn: func(item *Item) error { return tt.err }}
+			e := New(stage).WithCache(store.NewLocal(t.TempDir()), tt.retryAfter)
+			songs := []fs.Song{{Rank: 1, Artist: "Nobody", Title: "Unknown"}}
+
+			e.Enrich(context.Background(), "hnhh", songs, nil, nil)
+			e.Enrich(context.Background(), "hnhh", songs, nil, nil)
+			if stage.calls != tt.calls {
+				t.Errorf("Expected %d lookups, got %d", tt.calls, stage.calls)
+			}
+		})
+	}
+}
+
+func TestEnrich_CachesMBIDByISRC(t *testing.T) {
+	spotifyStage := &fakeStage{name: "spotify", fn: func(item *Item) error {
+		item.Track.ISRC = "USRC12400001"
+		return nil
+	}}
+	mbLookups := 0
+	mbStage := &fakeStage{name: "musicbrainz", fn: func(item *Item) error {
+		if item.Track.MBID == "" {
+			mbLookups++
+			item.Track.MBID = "saturn-mbid"
+		}
+		return nil
+	}}
+	e := New(spotifyStage, mbStage).WithCache(store.NewLocal(t.TempDir()), time.Hour)
+
+	songs := []fs.Song{{Rank: 1, Artist: "SZA", Title: "Saturn"}, {Rank: 2, Artist: "SZA", Title: "Saturn (Radio Edit)"}}
+	tracks, _ := e.Enrich(context.Background(), "billboard", songs, nil, nil)
+
+	if mbLookups != 1 || tracks[1].MBID != "saturn-mbid" {
+		t.Errorf("Expected the MBID of the shared ISRC from the cache, got %d lookups and %+v", mbLookups, tracks[1])
+	}
+}
@@ -0,0 +1,95 @@
+package enrichment
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/mager/musicbrainz-go/musicbrainz"
+
+	"melodex/catalog"
+	fs "melodex/firestore"
+	mb "melodex/musicbrainz"
+)
+
+// MusicBrainzStage finds the MusicBrainz recording ID for a track, and the
+// MusicBrainz IDs of its artists.
+type MusicBrainzStage struct {
+	mb *mb.MusicbrainzClient
+}
+
+// NewMusicBrainzStage creates a MusicBrainz lookup stage
+func NewMusicBrainzStage(mbc *mb.MusicbrainzClient) *MusicBrainzStage {
+	return &MusicBrainzStage{mb: mbc}
+}
+
+func (s *MusicBrainzStage) Name() string { return StageMusicBrainz }
+
+func (s *MusicBrainzStage) Enrich(ctx context.Context, item *Item) error {
+	if item.Track.MBID != "" {
+		return nil
+	}
+
+	recording, err := s.findRecording(ctx, item.Track.ISRC, item.Song.Artist, item.Song.Title)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil {
+		return fmt.Errorf("recording lookup: %w", err)
+	}
+	if recording == nil {
+		return fmt.Errorf("recording lookup: %w", ErrNoMatch)
+	}
+
+	item.Track.MBID = recording.ID
+	if recording.ArtistCredits != nil {
+		var credits []fs.ArtistCredit
+		for _, credit := range *recording.ArtistCredits {
+			if credit.Artist != nil {
+				credits = append(credits, fs.ArtistCredit{Name: credit.Name, MBID: credit.Artist.ID})
+			}
+		}
+		catalog.FillCredits(item.Track.Artists, credits)
+	}
+	return nil
+}
+
+// findRecording looks a recording up by ISRC first, then falls back to
+// artist and title search if the ISRC is not available. It returns nil and
+// the last search error if no search found one, or nil and no error if
+// every search succeeded without a match.
+func (s *MusicBrainzStage) findRecording(ctx context.Context, isrc, artist, title string) (*musicbrainz.Recording, error) {
+	var lastErr error
+	if isrc != "" {
+		searchRecsReq := musicbrainz.SearchRecordingsByISRCRequest{
+			ISRC: isrc,
+		}
+		recs, err := s.mb.SearchRecordingsByISRC(ctx, searchRecsReq)
+		if err != nil {
+			log.Printf("Error getting MBID by ISRC: %v", err)
+			lastErr = err
+		}
+		if len(recs.Recordings) > 0 {
+			return &recs.Recordings[0], nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	// Fall back to artist and title search
+	searchRecsReq := musicbrainz.SearchRecordingsByArtistAndTrackRequest{
+		Artist: artist,
+		Track:  title,
+	}
+	recs, err := s.mb.SearchRecordingsByArtistAndTrack(ctx, searchRecsReq)
+	if err != nil {
+		log.Printf("Error getting MBID by title and artist: %v", err)
+		return nil, err
+	}
+	if len(recs.Recordings) > 0 {
+		return &recs.Recordings[0], nil
+	}
+
+	return nil, lastErr
+}
@@ -0,0 +1,104 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	spotify "github.com/zmb3/spotify/v2"
+
+	"melodex/catalog"
+	fs "melodex/firestore"
+	"melodex/scoring"
+	spot "melodex/spotify"
+)
+
+// ErrNoMatch is returned by a stage that found nothing for the song.
+var ErrNoMatch = errors.New("no match")
+
+// SpotifyStage searches Spotify for the song and records its ISRC, ID and
+// artists.
+type SpotifyStage struct {
+	sp *spot.SpotifyClient
+}
+
+// NewSpotifyStage creates a Spotify search stage
+func NewSpotifyStage(sp *spot.SpotifyClient) *SpotifyStage {
+	return &SpotifyStage{sp: sp}
+}
+
+func (s *SpotifyStage) Name() string { return StageSpotify }
+
+// Enrich skips the search when the source already supplied a Spotify ID.
+func (s *SpotifyStage) Enrich(ctx context.Context, item *Item) error {
+	if item.Track.SpotifyID != "" {
+		return nil
+	}
+
+	q := buildSpotifyQuery(item.Song.Artist, item.Song.Title)
+	results, err := s.sp.Client.Search(ctx, q, spotify.SearchTypeTrack)
+	if err != nil {
+		return fmt.Errorf("search %q: %w", q, err)
+	}
+	if results.Tracks == nil || len(results.Tracks.Tracks) == 0 {
+		return fmt.Errorf("search %q: %w", q, ErrNoMatch)
+	}
+
+	track := results.Tracks.Tracks[0]
+	item.SpotifyTrack = &track
+	item.Track.SpotifyID = track.ID.String()
+	if isrc := track.ExternalIDs["isrc"]; isrc != "" {
+		item.Track.ISRC = isrc
+	}
+	item.Track.Artists = spotifyArtists(item.Track.Artists, track.Artists)
+	return nil
+}
+
+// spotifyArtists credits the artists of a matched Spotify track, which are
+// listed separately and with IDs, in place of the parsed credits. Spotify
+// doesn't mark featured artists, so the parsed role of an artist is kept;
+// otherwise the first artist is the primary one.
+func spotifyArtists(parsed []fs.ArtistCredit, artists []spotify.SimpleArtist) []fs.ArtistCredit {
+	if len(artists) == 0 {
+		return parsed
+	}
+
+	credits := make([]fs.ArtistCredit, len(artists))
+	for i, artist := range artists {
+		role := fs.RoleFeatured
+		if i == 0 {
+			role = fs.RolePrimary
+		}
+		for _, p := range parsed {
+			if scoring.NormalizeName(p.Name) == scoring.NormalizeName(artist.Name) {
+				role = p.Role
+			}
+		}
+		credits[i] = fs.ArtistCredit{Name: artist.Name, Role: role, SpotifyID: artist.ID.String()}
+	}
+	catalog.FillCredits(credits, parsed)
+	return credits
+}
+
+func buildSpotifyQuery(artist, title string) string {
+	// Clean up artist name by removing "Featuring" and similar words
+	patterns := []string{
+		" Featuring ", " featuring ",
+		" feat. ", " feat ",
+		" ft. ", " ft ",
+	}
+
+	cleanArtist := artist
+	for _, pattern := range patterns {
+		cleanArtist = strings.ReplaceAll(cleanArtist, pattern, " ")
+	}
+
+	var q strings.Builder
+	q.WriteString("artist:")
+	q.WriteString(cleanArtist)
+	q.WriteString(" ")
+	q.WriteString("track:")
+	q.WriteString(title)
+	return q.String()
+}
@@ -12,15 +12,15 @@ import (
 // DefaultTTL is the default max age for documents (7 days).
 const DefaultTTL = 7 * 24 * time.Hour
 
-// AllCollections returns the list of all melodex Firestore collections.
-func AllCollections() []string {
-	return []string{
-		"billboard",
-		"hnhh",
-		"spotify_new_releases",
-		"reddit_fresh",
-		"pitchfork_bnm",
-	}
+// DiscoveryCollection holds the materialized discovery feed, keyed by date.
+const DiscoveryCollection = "discovery"
+
+// AllCollections returns every date-keyed melodex collection: the given
+// source collections plus the discovery feed.
+func AllCollections(sources []string) []string {
+	collections := make([]string, 0, len(sources)+1)
+	collections = append(collections, sources...)
+	return append(collections, DiscoveryCollection)
 }
 
 // CleanupOldDocuments deletes documents older than maxAge from the given collections.
@@ -65,7 +65,8 @@ func CleanupOldDocuments(ctx context.Context, client *firestore.Client, collecti
 	return nil
 }
 
-// RunCleanup is a convenience wrapper that cleans all known collections with the default TTL.
-func RunCleanup(ctx context.Context, client *firestore.Client) error {
-	return CleanupOldDocuments(ctx, client, AllCollections(), DefaultTTL)
+// RunCleanup is a convenience wrapper that cleans the given source collections
+// and the discovery feed with the default TTL.
+func RunCleanup(ctx context.Context, client *firestore.Client, sources []string) error {
+	return CleanupOldDocuments(ctx, client, AllCollections(sources), DefaultTTL)
 }
@@ -19,16 +19,169 @@ type Track struct {
 	Thumb     string `json:"thumb,omitempty" firestore:"thumb,omitempty"`
 	ISRC      string `json:"isrc,omitempty" firestore:"isrc,omitempty"`
 	SpotifyID string `json:"spotifyID,omitempty" firestore:"spotifyID,omitempty"`
-	
+
 	// New fields for melodex v2
 	Source    string    `json:"source,omitempty" firestore:"source,omitempty"`
 	CreatedAt time.Time `json:"createdAt,omitempty" firestore:"createdAt,omitempty"`
+
+	// TrackID is the melodex ID of the track's record in the tracks collection
+	TrackID string `json:"trackID,omitempty" firestore:"trackID,omitempty"`
+
+	// Artists credited on the track, Artist is their free-text form
+	Artists []ArtistCredit `json:"artists,omitempty" firestore:"artists,omitempty"`
+
+	// Chart movement against the source's previous snapshot
+	Movement     string `json:"movement,omitempty" firestore:"movement,omitempty"`
+	PreviousRank int    `json:"previousRank,omitempty" firestore:"previousRank,omitempty"`
+	RankChange   int    `json:"rankChange,omitempty" firestore:"rankChange,omitempty"` // Places climbed, negative when falling
+}
+
+// Roles of an artist credited on a track
+const (
+	RolePrimary  = "primary"
+	RoleFeatured = "featured"
+)
+
+// ArtistCredit is an artist credited on a track
+type ArtistCredit struct {
+	Name      string `json:"name" firestore:"name"`
+	Role      string `json:"role" firestore:"role"`
+	SpotifyID string `json:"spotifyID,omitempty" firestore:"spotifyID,omitempty"`
+	MBID      string `json:"mbid,omitempty" firestore:"mbid,omitempty"`         // MusicBrainz artist ID
+	ArtistID  string `json:"artistID,omitempty" firestore:"artistID,omitempty"` // melodex ID in the artists collection
 }
 
-// ProvideDB provides a firestore client
-func ProvideDB() *firestore.Client {
-	projectID := "beatbrain-dev"
+// Chart movements of a track between two snapshots
+const (
+	MovementDebut     = "debut"    // Never seen on the source before
+	MovementReEntry   = "re-entry" // Back after dropping out
+	MovementUp        = "up"
+	MovementDown      = "down"
+	MovementUnchanged = "unchanged"
+	MovementDropped   = "dropped" // Gone from the later snapshot
+)
+
+// CanonicalTrack is the single record of a song across every source, keyed
+// by its melodex ID. Metadata is merged from every appearance.
+type CanonicalTrack struct {
+	ID          string            `json:"id" firestore:"id"`
+	Artist      string            `json:"artist" firestore:"artist"`
+	Title       string            `json:"title" firestore:"title"`
+	MBID        string            `json:"mbid,omitempty" firestore:"mbid,omitempty"`
+	ISRC        string            `json:"isrc,omitempty" firestore:"isrc,omitempty"`
+	SpotifyID   string            `json:"spotifyID,omitempty" firestore:"spotifyID,omitempty"`
+	Thumb       string            `json:"thumb,omitempty" firestore:"thumb,omitempty"`
+	Sources     []string          `json:"sources" firestore:"sources"`
+	FirstSeen   string            `json:"firstSeen" firestore:"firstSeen"`
+	LastSeen    string            `json:"lastSeen" firestore:"lastSeen"`
+	Artists     []ArtistCredit    `json:"artists,omitempty" firestore:"artists,omitempty"`
+	Appearances []TrackAppearance `json:"appearances" firestore:"appearances"` // Ordered by date, then source
+	UpdatedAt   time.Time         `json:"updatedAt" firestore:"updatedAt"`
+
+	// MergedInto is the ID of the record this one was merged into once the
+	// track got a better identifier. Merged records keep nothing else.
+	MergedInto string `json:"mergedInto,omitempty" firestore:"mergedInto,omitempty"`
+}
+
+// TrackAppearance is a canonical track's rank in one source's daily document
+type TrackAppearance struct {
+	Source string `json:"source" firestore:"source"`
+	Date   string `json:"date" firestore:"date"`
+	Rank   int    `json:"rank" firestore:"rank"`
+}
+
+// Artist is the record of an artist across every source, keyed by its
+// melodex ID, with the canonical tracks it is credited on
+type Artist struct {
+	ID        string    `json:"id" firestore:"id"`
+	Name      string    `json:"name" firestore:"name"`
+	SpotifyID string    `json:"spotifyID,omitempty" firestore:"spotifyID,omitempty"`
+	MBID      string    `json:"mbid,omitempty" firestore:"mbid,omitempty"`
+	Sources   []string  `json:"sources" firestore:"sources"`
+	Tracks    []string  `json:"tracks" firestore:"tracks"` // Track IDs, in the order first credited
+	FirstSeen string    `json:"firstSeen" firestore:"firstSeen"`
+	LastSeen  string    `json:"lastSeen" firestore:"lastSeen"`
+	UpdatedAt time.Time `json:"updatedAt" firestore:"updatedAt"`
+
+	// MergedInto is the ID of the record this one was merged into once the
+	// artist got a better identifier. Merged records keep nothing else.
+	MergedInto string `json:"mergedInto,omitempty" firestore:"mergedInto,omitempty"`
+}
+
+// EnrichmentEntry is a cached lookup result shared by every source. Entries
+// keyed by artist and title hold a song's metadata; entries keyed by ISRC
+// hold the MusicBrainz ID of the recording.
+type EnrichmentEntry struct {
+	ID         string         `json:"id" firestore:"id"`   // Hash of Key
+	Key        string         `json:"key" firestore:"key"` // "track:<artist> - <title>" or "isrc:<ISRC>"
+	ISRC       string         `json:"isrc,omitempty" firestore:"isrc,omitempty"`
+	SpotifyID  string         `json:"spotifyID,omitempty" firestore:"spotifyID,omitempty"`
+	MBID       string         `json:"mbid,omitempty" firestore:"mbid,omitempty"`
+	Thumb      string         `json:"thumb,omitempty" firestore:"thumb,omitempty"`
+	Artists    []ArtistCredit `json:"artists,omitempty" firestore:"artists,omitempty"`
+	RetryAfter time.Time      `json:"retryAfter,omitempty" firestore:"retryAfter,omitempty"` // Set while metadata is missing
+	UpdatedAt  time.Time      `json:"updatedAt" firestore:"updatedAt"`
+}
+
+// RateLimit is the state of a rate limit shared by every instance, such as
+// the MusicBrainz budget
+type RateLimit struct {
+	Name         string        `json:"name" firestore:"name"`
+	TAT          time.Time     `json:"tat" firestore:"tat"` // Theoretical arrival time of the next request
+	BlockedUntil time.Time     `json:"blockedUntil,omitempty" firestore:"blockedUntil,omitempty"`
+	Penalty      time.Duration `json:"penalty,omitempty" firestore:"penalty,omitempty"` // Added to the interval after slow-downs
+	UpdatedAt    time.Time     `json:"updatedAt" firestore:"updatedAt"`
+}
 
+// Snapshot is the layout of a daily source document
+type Snapshot struct {
+	Tracks    []Track   `json:"tracks" firestore:"tracks"`
+	UpdatedAt time.Time `json:"-" firestore:"-"` // Set by the store when reading
+}
+
+// Discovery is the materialized discovery feed of a date, rebuilt after
+// every full scrape
+type Discovery struct {
+	Date        string           `json:"date" firestore:"date"`
+	Profile     string           `json:"profile" firestore:"profile"` // Scoring profile the feed was ranked with
+	Sources     []string         `json:"sources" firestore:"sources"` // Collections with a snapshot that day
+	Tracks      []DiscoveryTrack `json:"tracks" firestore:"tracks"`
+	GeneratedAt time.Time        `json:"generatedAt" firestore:"generatedAt"`
+}
+
+// DiscoveryTrack is a scored, deduplicated track of the discovery feed
+type DiscoveryTrack struct {
+	Artist         string          `json:"artist" firestore:"artist"`
+	Title          string          `json:"title" firestore:"title"`
+	MBID           string          `json:"mbid,omitempty" firestore:"mbid,omitempty"`
+	ISRC           string          `json:"isrc,omitempty" firestore:"isrc,omitempty"`
+	SpotifyID      string          `json:"spotifyID,omitempty" firestore:"spotifyID,omitempty"`
+	Thumb          string          `json:"thumb,omitempty" firestore:"thumb,omitempty"`
+	Source         string          `json:"source" firestore:"source"` // Every matched source, comma separated
+	Rank           int             `json:"rank" firestore:"rank"`
+	CreatedAt      time.Time       `json:"createdAt,omitempty" firestore:"createdAt,omitempty"`
+	Score          float64         `json:"score" firestore:"score"`
+	WeeksOnChart   int             `json:"weeksOnChart,omitempty" firestore:"weeksOnChart,omitempty"`
+	SourceCount    int             `json:"sourceCount" firestore:"sourceCount"`
+	ScoreBreakdown *ScoreBreakdown `json:"scoreBreakdown,omitempty" firestore:"scoreBreakdown,omitempty"`
+
+	// "Artist - Title" of similar tracks that weren't merged with this one
+	PossibleDuplicates []string `json:"possibleDuplicates,omitempty" firestore:"possibleDuplicates,omitempty"`
+}
+
+// ScoreBreakdown explains how a track's score was computed
+type ScoreBreakdown struct {
+	SourceWeight     float64  `json:"sourceWeight" firestore:"sourceWeight"`
+	NormalizedRank   float64  `json:"normalizedRank" firestore:"normalizedRank"`
+	BaseScore        float64  `json:"baseScore" firestore:"baseScore"` // sourceWeight × normalizedRank
+	FreshnessBonus   float64  `json:"freshnessBonus" firestore:"freshnessBonus"`
+	CrossSourceBonus float64  `json:"crossSourceBonus" firestore:"crossSourceBonus"`
+	Sources          []string `json:"sources" firestore:"sources"` // Every source the track matched on
+	Profile          string   `json:"profile" firestore:"profile"` // Scoring profile used
+}
+
+// ProvideDB provides a firestore client for the given project
+func ProvideDB(projectID string) *firestore.Client {
 	client, err := firestore.NewClient(context.TODO(), projectID)
 	if err != nil {
 		log.Fatalf("Failed to create client: %v", err)
@@ -36,12 +189,19 @@ func ProvideDB() *firestore.Client {
 	return client
 }
 
-var Options = ProvideDB
-
 type Song struct {
 	Rank   int    `json:"rank"`
 	Title  string `json:"title"`
 	Artist string `json:"artist"`
+
+	// Identifiers for sources that already know them (e.g. Spotify)
+	ISRC      string `json:"isrc,omitempty"`
+	SpotifyID string `json:"spotifyID,omitempty"`
+	Thumb     string `json:"thumb,omitempty"`
+
+	// Artists, for sources that list them separately. Otherwise they are
+	// parsed from Artist.
+	Artists []ArtistCredit `json:"artists,omitempty"`
 }
 
 // PodcastShow represents a podcast show for Firestore storage
@@ -61,3 +221,82 @@ type PodcastShow struct {
 	FirstSeenAt  time.Time `json:"firstSeenAt" firestore:"firstSeenAt"`
 	LastUpdated  time.Time `json:"lastUpdated" firestore:"lastUpdated"`
 }
+
+// ScrapeRun records a single scrape of a single source
+type ScrapeRun struct {
+	ID            string    `json:"id" firestore:"id"`
+	JobID         string    `json:"jobID" firestore:"jobID"`
+	Source        string    `json:"source" firestore:"source"`
+	Trigger       string    `json:"trigger" firestore:"trigger"`
+	Debug         bool      `json:"debug,omitempty" firestore:"debug,omitempty"`
+	Status        string    `json:"status" firestore:"status"`
+	Scraped       int       `json:"scraped" firestore:"scraped"`
+	Enriched      int       `json:"enriched" firestore:"enriched"`
+	Reused        int       `json:"reused" firestore:"reused"`
+	Cached        int       `json:"cached" firestore:"cached"`
+	SpotifyMisses int       `json:"spotifyMisses" firestore:"spotifyMisses"`
+	MBIDMisses    int       `json:"mbidMisses" firestore:"mbidMisses"`
+	Error         string    `json:"error,omitempty" firestore:"error,omitempty"`
+	ErrorKind     string    `json:"errorKind,omitempty" firestore:"errorKind,omitempty"`
+	Errors        []string  `json:"errors,omitempty" firestore:"errors,omitempty"`     // Enrichment errors
+	Problems      []string  `json:"problems,omitempty" firestore:"problems,omitempty"` // Failed expectations of a degraded run
+	DocumentID    string    `json:"documentID,omitempty" firestore:"documentID,omitempty"`
+	StartedAt     time.Time `json:"startedAt" firestore:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt,omitempty" firestore:"finishedAt,omitempty"`
+	DurationMs    int64     `json:"durationMs" firestore:"durationMs"`
+}
+
+// Scrape job and per-source statuses
+const (
+	StatusQueued      = "queued"
+	StatusRunning     = "running"
+	StatusSucceeded   = "succeeded"
+	StatusSkipped     = "skipped"
+	StatusFailed      = "failed"
+	StatusPartial     = "partial"
+	StatusInterrupted = "interrupted"
+	StatusDegraded    = "degraded" // Scraped, but failed the source's expectations
+)
+
+// What started a scrape job
+const (
+	TriggerManual    = "manual"
+	TriggerScheduled = "scheduled"
+	TriggerBackfill  = "backfill"
+)
+
+// ScrapeJob tracks an asynchronous /scrape request or a scheduled run
+type ScrapeJob struct {
+	ID         string           `json:"id" firestore:"id"`
+	Target     string           `json:"target,omitempty" firestore:"target,omitempty"` // Empty = all sources
+	Trigger    string           `json:"trigger,omitempty" firestore:"trigger,omitempty"`
+	Debug      bool             `json:"debug,omitempty" firestore:"debug,omitempty"`
+	Status     string           `json:"status" firestore:"status"`
+	Sources    []SourceProgress `json:"sources" firestore:"sources"`
+	CreatedAt  time.Time        `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt  time.Time        `json:"updatedAt" firestore:"updatedAt"`
+	FinishedAt time.Time        `json:"finishedAt,omitempty" firestore:"finishedAt,omitempty"`
+	DurationMs int64            `json:"durationMs,omitempty" firestore:"durationMs,omitempty"`
+}
+
+// SourceProgress is the progress, and once finished the result, of one
+// source within a scrape job
+type SourceProgress struct {
+	Source     string         `json:"source" firestore:"source"`
+	Status     string         `json:"status" firestore:"status"`
+	StartedAt  time.Time      `json:"startedAt,omitempty" firestore:"startedAt,omitempty"`
+	FinishedAt time.Time      `json:"finishedAt,omitempty" firestore:"finishedAt,omitempty"`
+	DurationMs int64          `json:"durationMs,omitempty" firestore:"durationMs,omitempty"`
+	Scraped    int            `json:"scraped" firestore:"scraped"`
+	Enriched   int            `json:"enriched" firestore:"enriched"`
+	Reused     int            `json:"reused" firestore:"reused"`
+	Cached     int            `json:"cached" firestore:"cached"`
+	Failed     int            `json:"failed" firestore:"failed"`
+	Misses     map[string]int `json:"misses,omitempty" firestore:"misses,omitempty"`     // Enrichment failures per stage
+	Errors     []string       `json:"errors,omitempty" firestore:"errors,omitempty"`     // The first enrichment errors
+	Problems   []string       `json:"problems,omitempty" firestore:"problems,omitempty"` // Failed expectations of a degraded run
+	Error      string         `json:"error,omitempty" firestore:"error,omitempty"`
+	ErrorKind  string         `json:"errorKind,omitempty" firestore:"errorKind,omitempty"` // blocked, layout_changed, upstream_status or timeout
+	DocumentID string         `json:"documentID,omitempty" firestore:"documentID,omitempty"`
+	Tracks     []Track        `json:"tracks,omitempty" firestore:"tracks,omitempty"` // Only kept for debug jobs
+}
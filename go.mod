@@ -11,9 +11,14 @@ require (
 	github.com/gorilla/mux v1.8.1
 	github.com/kelseyhightower/envconfig v1.4.0
 	github.com/mager/musicbrainz-go v0.0.15
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/zmb3/spotify/v2 v2.4.3
 	go.uber.org/fx v1.23.0
 	golang.org/x/oauth2 v0.22.0
+	golang.org/x/text v0.20.0
+	google.golang.org/api v0.196.0
+	google.golang.org/grpc v1.66.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -59,13 +64,10 @@ require (
 	golang.org/x/net v0.31.0 // indirect
 	golang.org/x/sync v0.9.0 // indirect
 	golang.org/x/sys v0.27.0 // indirect
-	golang.org/x/text v0.20.0 // indirect
 	golang.org/x/time v0.6.0 // indirect
-	google.golang.org/api v0.196.0 // indirect
 	google.golang.org/appengine v1.6.8 // indirect
 	google.golang.org/genproto v0.0.0-20240903143218-8af14fe29dc1 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20240903143218-8af14fe29dc1 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240903143218-8af14fe29dc1 // indirect
-	google.golang.org/grpc v1.66.0 // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
 )
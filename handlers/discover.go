@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"melodex/config"
+	"melodex/discovery"
+	fs "melodex/firestore"
+	"melodex/scrapers"
+	"melodex/store"
+)
+
+// Page sizes of GET /discover
+const (
+	defaultDiscoverLimit = 50
+	maxDiscoverLimit     = 500
+)
+
+// discoverCursor is the position of the next page, encoded opaquely so
+// clients don't build their own. It is pinned to the query and to the build
+// of the feed it was issued for, so it can't skip or repeat tracks of
+// another list.
+type discoverCursor struct {
+	Date   string    `json:"d"`
+	Offset int       `json:"o"`
+	Query  string    `json:"q"`
+	Built  time.Time `json:"b"`
+}
+
+// discoverQuery hashes the parameters that shape the ranked list
+func discoverQuery(profile string, collections []string, minSources int) string {
+	sorted := slices.Sorted(slices.Values(collections))
+	basis := profile + "|" + strings.Join(sorted, ",") + "|" + strconv.Itoa(minSources)
+	sum := sha256.Sum256([]byte(basis))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c discoverCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (discoverCursor, error) {
+	var c discoverCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	if c.Offset < 0 {
+		return c, errors.New("negative offset")
+	}
+	return c, nil
+}
+
+// HandleDiscover returns the discovery feed of ?date= (default today): the
+// materialized feed when there is one, else the scored, deduplicated tracks
+// of every source. ?sources= ranks only those collections, ?minSources=
+// keeps tracks seen on at least that many sources, ?profile= picks a scoring
+// profile, ?explain=true includes each track's score breakdown, and ?cursor=
+// continues from the nextCursor of a previous page.
+func (h *TracksHandler) HandleDiscover(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	date := query.Get("date")
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultDiscoverLimit
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxDiscoverLimit)
+	}
+
+	minSources := 1
+	if m := query.Get("minSources"); m != "" {
+		n, err := strconv.Atoi(m)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid minSources", http.StatusBadRequest)
+			return
+		}
+		minSources = n
+	}
+
+	explain := false
+	if e := query.Get("explain"); e != "" {
+		b, err := strconv.ParseBool(e)
+		if err != nil {
+			http.Error(w, "Invalid explain", http.StatusBadRequest)
+			return
+		}
+		explain = b
+	}
+
+	var cursor *discoverCursor
+	if c := query.Get("cursor"); c != "" {
+		decoded, err := decodeCursor(c)
+		if err != nil || decoded.Date != date {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		cursor = &decoded
+	}
+
+	profile, ok := h.profiles.Get(h.profile)
+	if name := query.Get("profile"); name != "" {
+		if profile, ok = h.profiles.Get(name); !ok {
+			http.Error(w, "Unknown scoring profile: "+name, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var collections []string
+	if s := query.Get("sources"); s != "" {
+		for _, name := range strings.Split(s, ",") {
+			src, ok := h.source(strings.TrimSpace(name))
+			if !ok {
+				http.Error(w, "Unknown source: "+name, http.StatusBadRequest)
+				return
+			}
+			collections = append(collections, src.Collection())
+		}
+	}
+
+	queryHash := discoverQuery(profile.Name, collections, minSources)
+	if cursor != nil && cursor.Query != queryHash {
+		http.Error(w, "Cursor was issued for other query parameters", http.StatusBadRequest)
+		return
+	}
+
+	feed, err := h.discover(r.Context(), date, collections, profile)
+	if err != nil {
+		http.Error(w, "Failed to load discovery feed: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error loading discovery feed for %s: %v", date, err)
+		return
+	}
+
+	offset := 0
+	if cursor != nil {
+		if !cursor.Built.Equal(feed.GeneratedAt) {
+			http.Error(w, "Cursor was issued for an older build of the feed", http.StatusBadRequest)
+			return
+		}
+		offset = cursor.Offset
+	}
+
+	filtered := make([]fs.DiscoveryTrack, 0, len(feed.Tracks))
+	for _, track := range feed.Tracks {
+		if track.SourceCount < minSources {
+			continue
+		}
+		if !explain {
+			track.ScoreBreakdown = nil
+		}
+		filtered = append(filtered, track)
+	}
+
+	page := []fs.DiscoveryTrack{}
+	if offset < len(filtered) {
+		page = filtered[offset:min(offset+limit, len(filtered))]
+	}
+
+	resp := map[string]interface{}{
+		"date":    date,
+		"profile": feed.Profile,
+		"sources": feed.Sources,
+		"total":   len(filtered),
+		"count":   len(page),
+		"tracks":  page,
+	}
+	if next := offset + len(page); next < len(filtered) {
+		resp["nextCursor"] = discoverCursor{Date: date, Offset: next, Query: queryHash, Built: feed.GeneratedAt}.encode()
+	}
+	writeCached(w, r, time.Time{}, resp)
+}
+
+// HandleRebuildDiscovery rebuilds and saves the discovery feed of ?date=
+// (default today) from the stored snapshots
+func (h *TracksHandler) HandleRebuildDiscovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	profile, _ := h.profiles.Get(h.profile)
+	feed, err := discovery.Rebuild(r.Context(), h.store, date, feedCollections(h.sources), profile, h.retention)
+	if err != nil {
+		http.Error(w, "Failed to rebuild discovery feed: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error rebuilding discovery feed for %s: %v", date, err)
+		return
+	}
+
+	log.Printf("Rebuilt discovery feed for %s: %d tracks from %d sources", date, len(feed.Tracks), len(feed.Sources))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"date":    feed.Date,
+		"sources": feed.Sources,
+		"count":   len(feed.Tracks),
+	})
+}
+
+// discover returns the materialized feed of date, or builds one when it
+// hasn't been materialized, was ranked with another profile, or only some
+// collections were requested. Feeds built here have no GeneratedAt, as each
+// request builds them anew.
+func (h *TracksHandler) discover(ctx context.Context, date string, collections []string, profile config.ScoringProfile) (fs.Discovery, error) {
+	build := func(collections []string) (fs.Discovery, error) {
+		feed, err := discovery.Build(ctx, h.store, date, collections, profile, h.retention)
+		feed.GeneratedAt = time.Time{}
+		return feed, err
+	}
+	if len(collections) > 0 {
+		return build(collections)
+	}
+
+	feed, err := h.store.GetDiscovery(ctx, date)
+	if errors.Is(err, store.ErrNotFound) || err == nil && feed.Profile != profile.Name {
+		return build(feedCollections(h.sources))
+	}
+	return feed, err
+}
+
+// HandleProfiles lists the scoring profiles /discover accepts in ?profile=
+func (h *TracksHandler) HandleProfiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	profiles := h.profiles.All()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"default":  h.profile,
+		"count":    len(profiles),
+		"profiles": profiles,
+	})
+}
+
+// feedCollections returns the collections of the sources that take part in
+// run-all scrapes, which make up the discovery feed
+func feedCollections(sources *scrapers.Registry) []string {
+	var collections []string
+	for _, src := range sources.Scheduled() {
+		collections = append(collections, src.Collection())
+	}
+	return collections
+}
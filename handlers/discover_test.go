@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"melodex/config"
+	fs "melodex/firestore"
+)
+
+type discoverResponse struct {
+	Total      int                 `json:"total"`
+	Count      int                 `json:"count"`
+	Tracks     []fs.DiscoveryTrack `json:"tracks"`
+	NextCursor string              `json:"nextCursor"`
+}
+
+func discoverPage(t *testing.T, r http.Handler, query url.Values) discoverResponse {
+	t.Helper()
+	w := get(r, "/discover?"+query.Encode(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /discover?%s: expected 200, got %d: %s", query.Encode(), w.Code, w.Body)
+	}
+	var resp discoverResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestHandleDiscover(t *testing.T) {
+	r, st := newTracksRouter(t)
+	ctx := context.Background()
+	st.SaveSnapshot(ctx, "billboard", "2024-02-04", []fs.Track{
+		{Rank: 1, Artist: "SZA", Title: "Saturn", Source: "billboard"},
+		{Rank: 2, Artist: "Jack Harlow", Title: "Lovin On Me", Source: "billboard"},
+		{Rank: 3, Artist: "Tate McRae", Title: "greedy", Source: "billboard"},
+	})
+	st.SaveSnapshot(ctx, "hnhh", "2024-02-04", []fs.Track{
+		{Rank: 1, Artist: "SZA", Title: "Saturn", Source: "hnhh"},
+		{Rank: 2, Artist: "Future", Title: "Type Shit", Source: "hnhh"},
+	})
+
+	resp := discoverPage(t, r, url.Values{"date": {"2024-02-04"}})
+	if resp.Total != 4 || resp.Count != 4 || resp.NextCursor != "" {
+		t.Fatalf("Expected 4 deduplicated tracks on one page, got %+v", resp)
+	}
+	for i, track := range resp.Tracks {
+		if i > 0 && track.Score > resp.Tracks[i-1].Score {
+			t.Errorf("Expected tracks sorted by score, got %+v", resp.Tracks)
+		}
+		if track.Title == "Saturn" && track.SourceCount != 2 {
+			t.Errorf("Expected the cross-source track to be merged, got %+v", track)
+		}
+	}
+
+	if resp.Tracks[0].ScoreBreakdown != nil {
+		t.Errorf("Expected no score breakdown without explain, got %+v", resp.Tracks[0])
+	}
+
+	resp = discoverPage(t, r, url.Values{"date": {"2024-02-04"}, "explain": {"true"}})
+	for _, track := range resp.Tracks {
+		b := track.ScoreBreakdown
+		if b == nil || b.Profile != config.DefaultScoringProfileName {
+			t.Fatalf("Expected a score breakdown with explain, got %+v", track)
+		}
+		if track.Title == "Saturn" && (len(b.Sources) != 2 || b.Sources[0] != "billboard" || b.Sources[1] != "hnhh") {
+			t.Errorf("Expected the breakdown to list both sources, got %v", b.Sources)
+		}
+	}
+
+	resp = discoverPage(t, r, url.Values{"date": {"2024-02-04"}, "minSources": {"2"}})
+	if resp.Total != 1 || resp.Tracks[0].Title != "Saturn" {
+		t.Errorf("Expected only the track seen on both sources, got %+v", resp.Tracks)
+	}
+
+	resp = discoverPage(t, r, url.Values{"date": {"2024-02-04"}, "sources": {"hnhh"}})
+	if resp.Total != 2 {
+		t.Errorf("Expected only hnhh tracks, got %+v", resp.Tracks)
+	}
+}
+
+func TestHandleDiscover_Pagination(t *testing.T) {
+	r, st := newTracksRouter(t)
+	var tracks []fs.Track
+	for i, title := range []string{"A", "B", "C", "D", "E"} {
+		tracks = append(tracks, fs.Track{Rank: i + 1, Artist: "Artist", Title: title, Source: "billboard"})
+	}
+	st.SaveSnapshot(context.Background(), "billboard", "2024-02-04", tracks)
+
+	query := url.Values{"date": {"2024-02-04"}, "limit": {"2"}}
+	var titles []string
+	first := discoverPage(t, r, query).NextCursor
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Pagination did not terminate")
+		}
+		resp := discoverPage(t, r, query)
+		for _, track := range resp.Tracks {
+			titles = append(titles, track.Title)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", resp.NextCursor)
+	}
+
+	if len(titles) != 5 || titles[0] != "A" || titles[4] != "E" {
+		t.Errorf("Expected every track once in rank order, got %v", titles)
+	}
+
+	for _, bad := range []string{
+		"/discover?date=2024-02-04&cursor=garbage",
+		"/discover?date=2024-02-03&cursor=" + discoverCursor{Date: "2024-02-04", Offset: 2}.encode(),
+		"/discover?date=2024-02-04&minSources=2&cursor=" + url.QueryEscape(first),
+		"/discover?date=2024-02-04&sources=billboard&cursor=" + url.QueryEscape(first),
+		"/discover?sources=unknown",
+		"/discover?minSources=0",
+		"/discover?explain=maybe",
+	} {
+		if w := get(r, bad, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: expected 400, got %d", bad, w.Code)
+		}
+	}
+}
+
+func TestHandleDiscover_CursorPinnedToBuild(t *testing.T) {
+	r, st := newTracksRouter(t)
+	ctx := context.Background()
+	feed := fs.Discovery{
+		Date:        "2024-02-04",
+		Profile:     config.DefaultScoringProfileName,
+		GeneratedAt: time.Date(2024, 2, 4, 6, 0, 0, 0, time.UTC),
+	}
+	for _, title := range []string{"A", "B", "C"} {
+		feed.Tracks = append(feed.Tracks, fs.DiscoveryTrack{Artist: "Artist", Title: title, Source: "billboard", SourceCount: 1})
+	}
+	st.SaveDiscovery(ctx, feed)
+
+	query := url.Values{"date": {"2024-02-04"}, "limit": {"1"}}
+	cursor := discoverPage(t, r, query).NextCursor
+	query.Set("cursor", cursor)
+	if resp := discoverPage(t, r, query); resp.Tracks[0].Title != "B" {
+		t.Errorf("Expected the second page of the same build, got %+v", resp.Tracks)
+	}
+
+	// Once the feed is rebuilt, the old cursor no longer applies
+	feed.GeneratedAt = feed.GeneratedAt.Add(time.Hour)
+	st.SaveDiscovery(ctx, feed)
+	if w := get(r, "/discover?"+query.Encode(), nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a cursor of an older build, got %d", w.Code)
+	}
+}
+
+func TestHandleDiscover_ServesMaterializedFeed(t *testing.T) {
+	r, st := newTracksRouter(t)
+	ctx := context.Background()
+	st.SaveSnapshot(ctx, "billboard", "2024-02-04", []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn", Source: "billboard"}})
+	st.SaveDiscovery(ctx, fs.Discovery{
+		Date:    "2024-02-04",
+		Profile: config.DefaultScoringProfileName,
+		Tracks:  []fs.DiscoveryTrack{{Artist: "Materialized", Title: "Feed", Source: "billboard", Score: 1, SourceCount: 1}},
+	})
+
+	resp := discoverPage(t, r, url.Values{"date": {"2024-02-04"}})
+	if resp.Total != 1 || resp.Tracks[0].Artist != "Materialized" {
+		t.Errorf("Expected the stored feed, got %+v", resp.Tracks)
+	}
+
+	// Filtering by source ranks the snapshots instead
+	resp = discoverPage(t, r, url.Values{"date": {"2024-02-04"}, "sources": {"billboard"}})
+	if resp.Total != 1 || resp.Tracks[0].Artist != "SZA" {
+		t.Errorf("Expected the billboard snapshot, got %+v", resp.Tracks)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"melodex/scrapers"
+)
+
+// Error kinds reported on failed sources, so callers can tell a block
+// from a broken scraper without parsing messages.
+const (
+	errorKindBlocked        = "blocked"
+	errorKindLayoutChanged  = "layout_changed"
+	errorKindUpstreamStatus = "upstream_status"
+	errorKindTimeout        = "timeout"
+	errorKindDegraded       = "degraded"
+)
+
+// errorKind classifies a scrape error, or returns "" if it is unknown
+func errorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, scrapers.ErrBlocked):
+		return errorKindBlocked
+	case errors.Is(err, scrapers.ErrLayoutChanged):
+		return errorKindLayoutChanged
+	case errors.Is(err, scrapers.ErrUpstreamStatus):
+		return errorKindUpstreamStatus
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorKindTimeout
+	case errors.Is(err, errDegraded):
+		return errorKindDegraded
+	default:
+		return ""
+	}
+}
+
+// errorKindHTTPStatus maps an error kind to the status code returned for it
+func errorKindHTTPStatus(kind string) int {
+	switch kind {
+	case errorKindBlocked:
+		return http.StatusServiceUnavailable
+	case errorKindLayoutChanged, errorKindUpstreamStatus, errorKindDegraded:
+		return http.StatusBadGateway
+	case errorKindTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	fs "melodex/firestore"
+	spot "melodex/spotify"
+	"melodex/store"
+)
+
+// healthRunsWindow is how many recent runs of a source are inspected
+const healthRunsWindow = 10
+
+// Source health statuses
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded" // The last run scraped data that failed expectations
+	healthFailing  = "failing"  // The last run failed outright
+	healthUnknown  = "unknown"  // No conclusive runs yet
+)
+
+// SourceHealth summarises the recent runs of a source
+type SourceHealth struct {
+	Source              string        `json:"source"`
+	Status              string        `json:"status"`
+	ConsecutiveFailures int           `json:"consecutiveFailures"`
+	Problems            []string      `json:"problems,omitempty"`
+	LastRun             *fs.ScrapeRun `json:"lastRun,omitempty"`
+}
+
+// HandleHealth reports the health of every source from its run history,
+// of the Spotify client, and the MusicBrainz rate limiter's metrics. The
+// overall status is degraded as soon as one source is degraded or failing,
+// or Spotify is unreachable.
+func (h *ScrapeHandler) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	overall := healthOK
+	var sources []SourceHealth
+	for _, src := range h.sources.All() {
+		health, err := h.sourceHealth(r.Context(), src.Collection())
+		if err != nil {
+			http.Error(w, "Failed to load runs: "+err.Error(), http.StatusInternalServerError)
+			log.Printf("Error loading runs of %s: %v", src.Collection(), err)
+			return
+		}
+		if health.Status == healthDegraded || health.Status == healthFailing {
+			overall = healthDegraded
+		}
+		sources = append(sources, health)
+	}
+
+	response := map[string]interface{}{
+		"sources": sources,
+	}
+	if h.sp != nil {
+		spotify := h.sp.Health()
+		if spotify.Status == spot.HealthDegraded {
+			overall = healthDegraded
+		}
+		response["spotify"] = spotify
+	}
+	response["status"] = overall
+	if h.mb != nil {
+		response["musicbrainz"] = h.mb.Metrics()
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// sourceHealth derives a source's health from its latest conclusive runs.
+// Skipped, interrupted and debug runs say nothing about the source.
+func (h *ScrapeHandler) sourceHealth(ctx context.Context, collection string) (SourceHealth, error) {
+	health := SourceHealth{Source: collection, Status: healthUnknown}
+
+	runs, err := h.store.ListRuns(ctx, store.RunFilter{Source: collection, Limit: healthRunsWindow})
+	if err != nil {
+		return health, err
+	}
+
+	for _, run := range runs {
+		if run.Debug || run.Status == fs.StatusSkipped || run.Status == fs.StatusInterrupted {
+			continue
+		}
+
+		if health.LastRun == nil {
+			health.LastRun = &run
+			switch run.Status {
+			case fs.StatusSucceeded:
+				health.Status = healthOK
+			case fs.StatusDegraded:
+				health.Status = healthDegraded
+				health.Problems = run.Problems
+			default:
+				health.Status = healthFailing
+			}
+		}
+
+		if run.Status == fs.StatusSucceeded {
+			break
+		}
+		health.ConsecutiveFailures++
+	}
+	return health, nil
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"melodex/config"
+	fs "melodex/firestore"
+	"melodex/history"
+	"melodex/store"
+)
+
+// HandleHistory returns the chart history of the track with an ISRC on every
+// run-all source, or only on ?source=, between the snapshots of ?from= and
+// ?to=. from defaults to the start of the retention window and to to the
+// latest snapshot.
+func (h *TracksHandler) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	isrc := mux.Vars(r)["isrc"]
+
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	for _, date := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if from == "" {
+		from = history.From(to, h.retention)
+	}
+
+	collections := feedCollections(h.sources)
+	if name := query.Get("source"); name != "" {
+		src, ok := h.source(name)
+		if !ok {
+			http.Error(w, "Unknown source: "+name, http.StatusNotFound)
+			return
+		}
+		collections = []string{src.Collection()}
+	}
+
+	histories := []history.History{}
+	for _, collection := range collections {
+		chart, err := history.Load(r.Context(), h.store, collection, from, to)
+		if err != nil {
+			http.Error(w, "Failed to load chart history: "+err.Error(), http.StatusInternalServerError)
+			log.Printf("Error loading %s chart history: %v", collection, err)
+			return
+		}
+		if hist, ok := chart.LookupISRC(isrc); ok {
+			histories = append(histories, hist)
+		}
+	}
+
+	if len(histories) == 0 {
+		http.Error(w, "No chart history for ISRC "+isrc, http.StatusNotFound)
+		return
+	}
+	writeCached(w, r, time.Time{}, map[string]interface{}{
+		"isrc":    isrc,
+		"count":   len(histories),
+		"history": histories,
+	})
+}
+
+// HandleDiff classifies the tracks of a source between the snapshots of
+// ?from= and ?to=. to defaults to the latest snapshot and from to the one
+// before it.
+func (h *TracksHandler) HandleDiff(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["source"]
+	src, ok := h.source(name)
+	if !ok {
+		http.Error(w, "Unknown source: "+name, http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	for _, date := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Without from, only the retention window before to can hold it
+	start := from
+	if start == "" {
+		start = history.From(to, h.retention)
+	}
+	chart, err := history.Load(r.Context(), h.store, src.Collection(), start, to)
+	if err != nil {
+		h.storeError(w, src, err)
+		return
+	}
+	dates := chart.Dates()
+	if to == "" && len(dates) > 0 {
+		to = dates[len(dates)-1]
+	}
+	if from == "" {
+		// The snapshot before to
+		if i := sort.SearchStrings(dates, to); i > 0 {
+			from = dates[i-1]
+		}
+	}
+	if from == "" || from >= to {
+		http.Error(w, "Need two snapshots to diff, with from before to", http.StatusBadRequest)
+		return
+	}
+
+	changes, err := chart.Diff(from, to)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.storeError(w, src, err)
+		return
+	}
+
+	summary := make(map[string]int)
+	for _, change := range changes {
+		summary[change.Movement]++
+	}
+	writeCached(w, r, time.Time{}, map[string]interface{}{
+		"source":  src.Collection(),
+		"from":    from,
+		"to":      to,
+		"summary": summary,
+		"count":   len(changes),
+		"changes": changes,
+	})
+}
+
+// historyRetention is how long snapshots are kept, DefaultTTL when unset
+func historyRetention(cfg config.Config) time.Duration {
+	if cfg.HistoryRetention <= 0 {
+		return fs.DefaultTTL
+	}
+	return cfg.HistoryRetention
+}
@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"melodex/discovery"
+	"melodex/enrichment"
+	fs "melodex/firestore"
+	"melodex/scrapers"
+	"melodex/store"
+)
+
+// defaultJobsLimit is how many jobs GET /jobs returns without ?limit=
+const defaultJobsLimit = 20
+
+// jobStaleAfter is how long a running job may go without an update before
+// it is considered abandoned by a dead instance.
+const jobStaleAfter = 10 * time.Minute
+
+// jobSaveInterval is the minimum time between progress writes of a job.
+// A source finishing is always written.
+const jobSaveInterval = time.Second
+
+// jobTracker serialises updates to a running job and persists them, so
+// GET /jobs/{id} reflects progress from any instance.
+type jobTracker struct {
+	mu      sync.Mutex
+	store   store.Store
+	job     fs.ScrapeJob
+	version int       // Bumped by every save, to order writes
+	saved   time.Time // When progress was last saved
+
+	// saveMu orders writes outside mu, so an older copy of the job never
+	// replaces a newer one
+	saveMu       sync.Mutex
+	savedVersion int
+}
+
+// update replaces the entry of a source and saves the job, at most once per
+// jobSaveInterval unless the source finished
+func (t *jobTracker) update(result fs.SourceProgress) {
+	finished := !result.FinishedAt.IsZero()
+
+	t.mu.Lock()
+	for i := range t.job.Sources {
+		if t.job.Sources[i].Source == result.Source {
+			t.job.Sources[i] = result
+			break
+		}
+	}
+	t.job.Status = fs.StatusRunning
+
+	var job fs.ScrapeJob
+	var version int
+	write := finished || time.Since(t.saved) >= jobSaveInterval
+	if write {
+		job, version = t.snapshot()
+	}
+	t.mu.Unlock()
+
+	if write {
+		t.save(job, version)
+	}
+	if finished {
+		t.saveRun(result)
+	}
+}
+
+// snapshot stamps the job and returns a copy to save. Callers hold mu.
+func (t *jobTracker) snapshot() (fs.ScrapeJob, int) {
+	t.version++
+	t.saved = time.Now()
+	t.job.UpdatedAt = t.saved
+
+	job := t.job
+	job.Sources = append([]fs.SourceProgress(nil), t.job.Sources...)
+	return job, t.version
+}
+
+// saveRun records the finished result of a source in the run history
+func (t *jobTracker) saveRun(result fs.SourceProgress) {
+	run := fs.ScrapeRun{
+		ID:            t.job.ID + "-" + result.Source,
+		JobID:         t.job.ID,
+		Source:        result.Source,
+		Trigger:       t.job.Trigger,
+		Debug:         t.job.Debug,
+		Status:        result.Status,
+		Scraped:       result.Scraped,
+		Enriched:      result.Enriched,
+		Reused:        result.Reused,
+		Cached:        result.Cached,
+		SpotifyMisses: result.Misses[enrichment.StageSpotify],
+		MBIDMisses:    result.Misses[enrichment.StageMusicBrainz],
+		Error:         result.Error,
+		ErrorKind:     result.ErrorKind,
+		Errors:        result.Errors,
+		Problems:      result.Problems,
+		DocumentID:    result.DocumentID,
+		StartedAt:     result.StartedAt,
+		FinishedAt:    result.FinishedAt,
+		DurationMs:    result.DurationMs,
+	}
+	if err := t.store.SaveRun(context.Background(), run); err != nil {
+		log.Printf("Error saving run %s: %v", run.ID, err)
+	}
+}
+
+// finish derives the overall job status from its sources, saves the job
+// and returns the final report
+func (t *jobTracker) finish() fs.ScrapeJob {
+	t.mu.Lock()
+	t.job.Status = overallStatus(t.job.Sources)
+	t.job.FinishedAt = time.Now()
+	t.job.DurationMs = t.job.FinishedAt.Sub(t.job.CreatedAt).Milliseconds()
+	job, version := t.snapshot()
+	t.mu.Unlock()
+
+	t.save(job, version)
+	return job
+}
+
+// overallStatus combines source results: interrupted if any source was cut
+// short, failed only if every source failed or was degraded, partial if some
+// did, otherwise succeeded
+func overallStatus(results []fs.SourceProgress) string {
+	failed, interrupted := 0, 0
+	for _, r := range results {
+		switch r.Status {
+		case fs.StatusFailed, fs.StatusDegraded:
+			failed++
+		case fs.StatusInterrupted:
+			interrupted++
+		}
+	}
+
+	switch {
+	case interrupted > 0:
+		return fs.StatusInterrupted
+	case failed == 0:
+		return fs.StatusSucceeded
+	case failed == len(results):
+		return fs.StatusFailed
+	default:
+		return fs.StatusPartial
+	}
+}
+
+// allSkipped reports whether every source was skipped, as today's snapshot
+// already existed
+func allSkipped(results []fs.SourceProgress) bool {
+	for _, r := range results {
+		if r.Status != fs.StatusSkipped {
+			return false
+		}
+	}
+	return len(results) > 0
+}
+
+// reportHTTPStatus maps a finished job to the status code of a synchronous report.
+// A failed job whose sources all failed the same known way reports that kind's status.
+func reportHTTPStatus(job fs.ScrapeJob) int {
+	skipped := 0
+	kind, sameKind := "", true
+	for i, r := range job.Sources {
+		if r.Status == fs.StatusSkipped {
+			skipped++
+		}
+		if r.Status == fs.StatusFailed || r.Status == fs.StatusDegraded {
+			if i > 0 && r.ErrorKind != kind {
+				sameKind = false
+			}
+			kind = r.ErrorKind
+		}
+	}
+
+	switch {
+	case job.Status == fs.StatusInterrupted:
+		return http.StatusServiceUnavailable
+	case job.Status == fs.StatusFailed && sameKind && kind != "":
+		return errorKindHTTPStatus(kind)
+	case job.Status == fs.StatusFailed:
+		return http.StatusBadGateway
+	case job.Status == fs.StatusPartial:
+		return http.StatusMultiStatus
+	case skipped > 0 && skipped == len(job.Sources):
+		return http.StatusConflict
+	default:
+		return http.StatusOK
+	}
+}
+
+// save persists a copy of the job from snapshot, unless a newer copy was
+// already saved. It deliberately ignores the job's context, so the result of
+// a cancelled job is still recorded.
+func (t *jobTracker) save(job fs.ScrapeJob, version int) {
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+
+	if version <= t.savedVersion {
+		return
+	}
+	t.savedVersion = version
+	if err := t.store.SaveJob(context.Background(), job); err != nil {
+		log.Printf("Error saving job %s: %v", job.ID, err)
+	}
+}
+
+// newJob records a queued job for the given sources
+func (h *ScrapeHandler) newJob(ctx context.Context, trigger, target string, debug bool, sources []scrapers.Source, podcasts bool) (*jobTracker, error) {
+	now := time.Now()
+	job := fs.ScrapeJob{
+		ID:        newJobID(now),
+		Target:    target,
+		Trigger:   trigger,
+		Debug:     debug,
+		Status:    fs.StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, src := range sources {
+		job.Sources = append(job.Sources, fs.SourceProgress{Source: src.Collection(), Status: fs.StatusQueued})
+	}
+	if podcasts {
+		job.Sources = append(job.Sources, fs.SourceProgress{Source: store.PodcastShowsCollection, Status: fs.StatusQueued})
+	}
+
+	if err := h.store.SaveJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Queued scrape job %s (target=%q, %d sources)", job.ID, target, len(job.Sources))
+	return &jobTracker{store: h.store, job: job}, nil
+}
+
+// runJob scrapes every source of a job concurrently and returns the final
+// report. Cancelling ctx interrupts the sources still running.
+func (h *ScrapeHandler) runJob(ctx context.Context, t *jobTracker, sources []scrapers.Source, podcasts bool) fs.ScrapeJob {
+	defer h.jobs.Done()
+	debug, target := t.job.Debug, t.job.Target
+	var wg sync.WaitGroup
+
+	for _, src := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.runSource(ctx, src, debug, t.update)
+		}()
+	}
+
+	if podcasts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.runPodcastsSource(ctx, t.update)
+		}()
+	}
+
+	wg.Wait()
+
+	// Rebuild today's discovery feed and run TTL cleanup after a full scrape
+	// cycle, and after each scheduled run as sources run on their own cadence.
+	// Nothing changed when every snapshot already existed.
+	if (target == "" || t.job.Trigger == fs.TriggerScheduled) && ctx.Err() == nil && !allSkipped(t.job.Sources) {
+		h.afterScrape(ctx, debug)
+	}
+
+	job := t.finish()
+	log.Printf("Scrape job %s finished: %s", job.ID, job.Status)
+	return job
+}
+
+// afterScrape rebuilds today's discovery feed from the stored snapshots and
+// deletes expired snapshots and feeds.
+func (h *ScrapeHandler) afterScrape(ctx context.Context, debug bool) {
+	if !debug {
+		today := time.Now().Format("2006-01-02")
+		feed, err := discovery.Rebuild(ctx, h.store, today, feedCollections(h.sources), h.profile, h.retention)
+		if err != nil {
+			log.Printf("Error rebuilding discovery feed: %v", err)
+		} else {
+			log.Printf("Rebuilt discovery feed for %s: %d tracks from %d sources", today, len(feed.Tracks), len(feed.Sources))
+		}
+	}
+
+	if err := h.store.Cleanup(ctx, fs.AllCollections(h.sources.Collections()), h.retention); err != nil {
+		log.Printf("Error during TTL cleanup: %v", err)
+	}
+}
+
+// RunScheduled runs a scrape job for a single source and returns its report.
+// The job stops when ctx is done or the handler shuts down.
+func (h *ScrapeHandler) RunScheduled(ctx context.Context, src scrapers.Source) (fs.ScrapeJob, error) {
+	if !h.startJob() {
+		return fs.ScrapeJob{}, h.ctx.Err()
+	}
+
+	tracker, err := h.newJob(ctx, fs.TriggerScheduled, src.Target(), false, []scrapers.Source{src}, false)
+	if err != nil {
+		h.jobs.Done()
+		return fs.ScrapeJob{}, err
+	}
+
+	ctx, cancel := h.withShutdown(ctx)
+	defer cancel()
+	return h.runJob(ctx, tracker, []scrapers.Source{src}, false), nil
+}
+
+// withShutdown derives a context that is also cancelled when the handler shuts down
+func (h *ScrapeHandler) withShutdown(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(h.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// runPodcastsSource runs default podcast discovery as part of a job
+func (h *ScrapeHandler) runPodcastsSource(ctx context.Context, progress progressFunc) fs.SourceProgress {
+	result := fs.SourceProgress{
+		Source:    store.PodcastShowsCollection,
+		Status:    fs.StatusRunning,
+		StartedAt: time.Now(),
+	}
+	progress(result)
+
+	responses, err := h.runPodcasts(ctx, PodcastScrapeRequest{})
+	for _, r := range responses {
+		result.Scraped += r.ShowsFound
+		result.Enriched += r.NewShows
+	}
+	switch {
+	case ctx.Err() != nil:
+		result.Status = fs.StatusInterrupted
+		result.Error = ctx.Err().Error()
+	case err != nil:
+		result.Status = fs.StatusFailed
+		result.Error = err.Error()
+	default:
+		result.Status = fs.StatusSucceeded
+	}
+
+	result.FinishedAt = time.Now()
+	result.DurationMs = result.FinishedAt.Sub(result.StartedAt).Milliseconds()
+	progress(result)
+	return result
+}
+
+// RecoverJobs marks jobs abandoned by a previous instance as interrupted.
+func (h *ScrapeHandler) RecoverJobs(ctx context.Context) error {
+	jobs, err := h.store.ListJobs(ctx, defaultJobsLimit)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if job.Status != fs.StatusQueued && job.Status != fs.StatusRunning {
+			continue
+		}
+		if time.Since(job.UpdatedAt) < jobStaleAfter {
+			continue
+		}
+
+		job.Status = fs.StatusInterrupted
+		for i := range job.Sources {
+			if job.Sources[i].Status == fs.StatusQueued || job.Sources[i].Status == fs.StatusRunning {
+				job.Sources[i].Status = fs.StatusInterrupted
+			}
+		}
+		job.UpdatedAt = time.Now()
+		if err := h.store.SaveJob(ctx, job); err != nil {
+			return err
+		}
+		log.Printf("Marked abandoned scrape job %s as interrupted", job.ID)
+	}
+	return nil
+}
+
+// HandleJob returns a single scrape job with per-source progress
+func (h *ScrapeHandler) HandleJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	job, err := h.store.GetJob(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load job: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error loading job %s: %v", id, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(job)
+}
+
+// HandleJobs lists recent scrape jobs, newest first
+func (h *ScrapeHandler) HandleJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := defaultJobsLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	jobs, err := h.store.ListJobs(r.Context(), limit)
+	if err != nil {
+		http.Error(w, "Failed to list jobs: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error listing jobs: %v", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count": len(jobs),
+		"jobs":  jobs,
+	})
+}
+
+// newJobID returns a sortable, unique job ID such as 20240204T162300-1a2b3c4d
+func newJobID(now time.Time) string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return now.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
@@ -3,12 +3,15 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	fs "melodex/firestore"
 	"melodex/scrapers"
+	"melodex/store"
 )
 
 // PodcastScrapeRequest represents the request body for podcast scraping
@@ -27,9 +30,12 @@ type PodcastScrapeResponse struct {
 	Message    string `json:"message"`
 }
 
+// errUnknownCategory is returned when a requested podcast category does not exist
+var errUnknownCategory = errors.New("unknown category")
+
 // HandlePodcasts handles podcast show discovery requests
 func (h *ScrapeHandler) HandlePodcasts(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 	w.Header().Set("Content-Type", "application/json")
 
 	// Parse request
@@ -39,6 +45,17 @@ func (h *ScrapeHandler) HandlePodcasts(w http.ResponseWriter, r *http.Request) {
 		req = PodcastScrapeRequest{}
 	}
 
+	results, err := h.runPodcasts(ctx, req)
+	if errors.Is(err, errUnknownCategory) {
+		http.Error(w, "Unknown category: "+req.Category, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
+
+// runPodcasts discovers podcast shows for the requested categories and saves them to the catalog
+func (h *ScrapeHandler) runPodcasts(ctx context.Context, req PodcastScrapeRequest) ([]PodcastScrapeResponse, error) {
 	// Set defaults
 	if req.MaxShows == 0 {
 		req.MaxShows = 15
@@ -47,24 +64,8 @@ func (h *ScrapeHandler) HandlePodcasts(w http.ResponseWriter, r *http.Request) {
 	// If fresh mode, nuke the collection first
 	if req.Fresh {
 		log.Println("Fresh mode: deleting all existing podcast_shows...")
-		iter := h.db.Collection("podcast_shows").Documents(ctx)
-		batch := h.db.Batch()
-		batchCount := 0
-		for {
-			doc, err := iter.Next()
-			if err != nil {
-				break
-			}
-			batch.Delete(doc.Ref)
-			batchCount++
-			if batchCount >= 500 {
-				_, _ = batch.Commit(ctx)
-				batch = h.db.Batch()
-				batchCount = 0
-			}
-		}
-		if batchCount > 0 {
-			_, _ = batch.Commit(ctx)
+		if err := h.store.DeletePodcastShows(ctx); err != nil {
+			log.Printf("Error deleting podcast_shows: %v", err)
 		}
 		log.Printf("Deleted existing podcast_shows for fresh scrape")
 	}
@@ -83,8 +84,7 @@ func (h *ScrapeHandler) HandlePodcasts(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if len(categoriesToScrape) == 0 {
-			http.Error(w, "Unknown category: "+req.Category, http.StatusBadRequest)
-			return
+			return nil, fmt.Errorf("%w: %s", errUnknownCategory, req.Category)
 		}
 	} else {
 		// Scrape all default categories
@@ -97,7 +97,10 @@ func (h *ScrapeHandler) HandlePodcasts(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Scraping podcasts for category: %s", cat.Category)
 
 		// Scrape shows for this category
-		shows, err := scrapers.ScrapePodcastShows(*h.sp.Client, cat.Queries, req.MaxShows)
+		shows, err := scrapers.ScrapePodcastShows(ctx, *h.sp.Client, cat.Queries, req.MaxShows)
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
 		if err != nil {
 			log.Printf("Error scraping category %s: %v", cat.Category, err)
 			results = append(results, PodcastScrapeResponse{
@@ -111,10 +114,14 @@ func (h *ScrapeHandler) HandlePodcasts(w http.ResponseWriter, r *http.Request) {
 		newShows := 0
 		for _, show := range shows {
 			// Check if show already exists
-			docRef := h.db.Collection("podcast_shows").Doc(show.ID)
-			doc, err := docRef.Get(ctx)
+			existingShow, err := h.store.GetPodcastShow(ctx, show.ID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				// Skip rather than overwrite a show that could not be read
+				log.Printf("Error reading show %s: %v", show.ID, err)
+				continue
+			}
 
-			if err != nil || !doc.Exists() {
+			if errors.Is(err, store.ErrNotFound) {
 				// New show - create it
 				podcastShow := fs.PodcastShow{
 					ID:           show.ID,
@@ -133,31 +140,27 @@ func (h *ScrapeHandler) HandlePodcasts(w http.ResponseWriter, r *http.Request) {
 					LastUpdated:  time.Now(),
 				}
 
-				_, err = docRef.Set(ctx, podcastShow)
+				err = h.store.SavePodcastShow(ctx, podcastShow)
 				if err != nil {
 					log.Printf("Error saving show %s: %v", show.ID, err)
 					continue
 				}
 				newShows++
 			} else {
-				// Existing show - update categories if needed
-				var existingShow fs.PodcastShow
-				if err := doc.DataTo(&existingShow); err == nil {
-					// Add new category if not already present
-					categoryExists := false
-					for _, c := range existingShow.Categories {
-						if c == cat.Category {
-							categoryExists = true
-							break
-						}
+				// Existing show - add new category if not already present
+				categoryExists := false
+				for _, c := range existingShow.Categories {
+					if c == cat.Category {
+						categoryExists = true
+						break
 					}
-					if !categoryExists {
-						existingShow.Categories = append(existingShow.Categories, cat.Category)
-						existingShow.LastUpdated = time.Now()
-						_, err = docRef.Set(ctx, existingShow)
-						if err != nil {
-							log.Printf("Error updating show %s: %v", show.ID, err)
-						}
+				}
+				if !categoryExists {
+					existingShow.Categories = append(existingShow.Categories, cat.Category)
+					existingShow.LastUpdated = time.Now()
+					err = h.store.SavePodcastShow(ctx, existingShow)
+					if err != nil {
+						log.Printf("Error updating show %s: %v", show.ID, err)
 					}
 				}
 			}
@@ -173,7 +176,7 @@ func (h *ScrapeHandler) HandlePodcasts(w http.ResponseWriter, r *http.Request) {
 		log.Printf("Category %s: found %d shows, %d new", cat.Category, len(shows), newShows)
 	}
 
-	json.NewEncoder(w).Encode(results)
+	return results, nil
 }
 
 // HandlePodcastCategories returns all available podcast categories
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"melodex/store"
+)
+
+// defaultRunsLimit is how many runs GET /runs returns without ?limit=
+const defaultRunsLimit = 100
+
+// HandleRuns lists recorded scrape runs, newest first. ?source= filters by
+// collection, ?since= takes an RFC 3339 time or a YYYY-MM-DD date.
+func (h *ScrapeHandler) HandleRuns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	filter := store.RunFilter{
+		Source: query.Get("source"),
+		Limit:  defaultRunsLimit,
+	}
+
+	if since := query.Get("since"); since != "" {
+		t, err := parseSince(since)
+		if err != nil {
+			http.Error(w, "Invalid since, expected RFC 3339 or YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		filter.Since = t
+	}
+
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	runs, err := h.store.ListRuns(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to list runs: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error listing runs: %v", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count": len(runs),
+		"runs":  runs,
+	})
+}
+
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"melodex/scheduler"
+)
+
+type ScheduleHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+func NewScheduleHandler(s *scheduler.Scheduler) *ScheduleHandler {
+	return &ScheduleHandler{scheduler: s}
+}
+
+// Handle lists every source with its cadence and its next and last scheduled run
+func (h *ScheduleHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	entries := h.scheduler.Entries()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":   len(entries),
+		"sources": entries,
+	})
+}
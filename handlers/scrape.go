@@ -3,32 +3,102 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"slices"
-
-	"cloud.google.com/go/firestore"
+	"sync"
+	"time"
 
+	"melodex/config"
+	"melodex/enrichment"
 	fs "melodex/firestore"
 	mb "melodex/musicbrainz"
+	"melodex/scrapers"
 	spot "melodex/spotify"
+	"melodex/store"
 )
 
+// podcastsTarget is the /scrape target for podcast show discovery.
+const podcastsTarget = "spotify-podcasts"
+
+// errAlreadyExists is reported for sources whose document for today exists
+var errAlreadyExists = errors.New("data for today already exists")
+
 type ScrapeHandler struct {
-	db *firestore.Client
-	sp *spot.SpotifyClient
-	mb *mb.MusicbrainzClient
+	store    store.Store
+	sp       *spot.SpotifyClient
+	mb       *mb.MusicbrainzClient // Reported by /health
+	enricher *enrichment.Enricher
+	sources  *scrapers.Registry
+	profile  config.ScoringProfile // Ranks the discovery feed after run-all scrapes
+
+	// How long snapshots are kept, and how far back chart history reaches
+	retention time.Duration
+
+	// ctx is cancelled by Shutdown to stop every running job. mu makes
+	// starting a job and shutting down exclusive, so no job is added to jobs
+	// once Shutdown waits on it.
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	jobs   sync.WaitGroup
 }
 
 func NewScrapeHandler(
-	db *firestore.Client,
+	st store.Store,
 	sp *spot.SpotifyClient,
-	mb *mb.MusicbrainzClient,
+	mbc *mb.MusicbrainzClient,
+	enricher *enrichment.Enricher,
+	sources *scrapers.Registry,
+	cfg config.Config,
 ) *ScrapeHandler {
+	ctx, cancel := context.WithCancel(context.Background())
+	profile, _ := cfg.ScoringProfiles.Get(cfg.ScoringProfile)
 	return &ScrapeHandler{
-		db: db,
-		sp: sp,
-		mb: mb,
+		store:    st,
+		sp:       sp,
+		mb:       mbc,
+		enricher: enricher,
+		sources:  sources,
+		profile:  profile,
+
+		retention: historyRetention(cfg),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// startJob counts a job as running, or returns false when shutting down.
+// runJob marks it done.
+func (h *ScrapeHandler) startJob() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.ctx.Err() != nil {
+		return false
+	}
+	h.jobs.Add(1)
+	return true
+}
+
+// Shutdown cancels running jobs and waits until they have recorded their
+// results, or until ctx is done.
+func (h *ScrapeHandler) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.cancel()
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -37,8 +107,13 @@ type ScrapeHandlerReq struct {
 }
 
 type ScrapeHandlerResp struct {
+	JobID     string `json:"jobID"`
+	Status    string `json:"status"`
+	StatusURL string `json:"statusURL"`
 }
 
+// Handle queues a scrape job for a target (or every source when no target
+// is given) and responds immediately with the job ID.
 func (h *ScrapeHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	// Parse request body
@@ -50,91 +125,86 @@ func (h *ScrapeHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 	target := req.Target
 
-	// Handle default case where no target is provided — run ALL scrapers concurrently
-	if target == "" {
-		errChan := make(chan error, 6)
-
-		go func() {
-			h.HandleBillboard(w, r)
-			errChan <- nil
-		}()
-
-		go func() {
-			h.HandleHotNewHipHop(w, r)
-			errChan <- nil
-		}()
-
-		go func() {
-			h.HandleSpotifyNewReleases(w, r)
-			errChan <- nil
-		}()
-
-		go func() {
-			h.HandleReddit(w, r)
-			errChan <- nil
-		}()
-
-		go func() {
-			h.HandlePitchfork(w, r)
-			errChan <- nil
-		}()
-
-		go func() {
-			h.HandlePodcasts(w, r)
-			errChan <- nil
-		}()
-
-		// Collect results from all goroutines
-		for i := 0; i < 6; i++ {
-			if err := <-errChan; err != nil {
-				log.Printf("Error in scraping: %v", err)
-			}
+	var sources []scrapers.Source
+	podcasts := false
+	switch target {
+	case "":
+		// Run ALL scrapers concurrently
+		sources = h.sources.Scheduled()
+		podcasts = true
+	case podcastsTarget:
+		// Podcasts build a catalog rather than a daily snapshot, so they are not a Source
+		podcasts = true
+	default:
+		src, ok := h.sources.ByTarget(target)
+		if !ok {
+			http.Error(w, "Invalid target", http.StatusBadRequest)
+			log.Printf("Invalid target: %s", target)
+			return
 		}
-		close(errChan)
+		sources = []scrapers.Source{src}
+	}
 
-		// Run Firestore TTL cleanup after all scrapes complete
-		ctx := context.Background()
-		if err := fs.RunCleanup(ctx, h.db); err != nil {
-			log.Printf("Error during TTL cleanup: %v", err)
-		}
+	h.queue(w, r, target, sources, podcasts)
+}
+
+// HandleTarget returns a handler that queues a scrape of a single registered source.
+func (h *ScrapeHandler) HandleTarget(src scrapers.Source) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		h.queue(w, r, src.Target(), []scrapers.Source{src}, false)
+	}
+}
 
+// queue starts a scrape job. With ?wait=true the job runs inside the request
+// and the response is the combined report of every source. ?trigger=backfill
+// marks the job as a backfill.
+func (h *ScrapeHandler) queue(w http.ResponseWriter, r *http.Request, target string, sources []scrapers.Source, podcasts bool) {
+	debugMode := r.URL.Query().Get("debug") == "true"
+	wait := r.URL.Query().Get("wait") == "true"
+
+	// Backfill scripts tag their runs so they stand out in the run history
+	trigger := fs.TriggerManual
+	switch t := r.URL.Query().Get("trigger"); t {
+	case "", fs.TriggerManual:
+	case fs.TriggerBackfill:
+		trigger = t
+	default:
+		http.Error(w, "Invalid trigger", http.StatusBadRequest)
 		return
 	}
 
-	// Valid targets
-	validTargets := []string{
-		"testing",
-		"billboard-hot-100",
-		"hot-new-hip-hop",
-		"spotify-new-releases",
-		"reddit-fresh",
-		"pitchfork-bnm",
-		"spotify-podcasts",
+	if !h.startJob() {
+		http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+		return
 	}
 
-	if !slices.Contains(validTargets, target) {
-		http.Error(w, "Invalid target", http.StatusBadRequest)
-		log.Printf("Invalid target: %s", target)
+	tracker, err := h.newJob(r.Context(), trigger, target, debugMode, sources, podcasts)
+	if err != nil {
+		h.jobs.Done()
+		http.Error(w, "Failed to queue scrape job: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Failed to queue scrape job: %v", err)
 		return
 	}
+	job := tracker.job
 
-	// Scrape based on target
-	switch target {
-	case "testing":
-		h.HandleTesting(w, r)
-	case "billboard-hot-100":
-		h.HandleBillboard(w, r)
-	case "hot-new-hip-hop":
-		h.HandleHotNewHipHop(w, r)
-	case "spotify-new-releases":
-		h.HandleSpotifyNewReleases(w, r)
-	case "reddit-fresh":
-		h.HandleReddit(w, r)
-	case "pitchfork-bnm":
-		h.HandlePitchfork(w, r)
-	case "spotify-podcasts":
-		h.HandlePodcasts(w, r)
-	default:
-		log.Printf("Unhandled target: %s", target)
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	if wait {
+		// A waiting job stops when the client goes away or on shutdown
+		ctx, cancel := h.withShutdown(r.Context())
+		defer cancel()
+
+		report := h.runJob(ctx, tracker, sources, podcasts)
+		w.WriteHeader(reportHTTPStatus(report))
+		json.NewEncoder(w).Encode(report)
+		return
 	}
+
+	go h.runJob(h.ctx, tracker, sources, podcasts)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(ScrapeHandlerResp{
+		JobID:     job.ID,
+		Status:    job.Status,
+		StatusURL: "/jobs/" + job.ID,
+	})
 }
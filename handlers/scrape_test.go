@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"melodex/config"
+	"melodex/enrichment"
+	fs "melodex/firestore"
+	"melodex/scrapers"
+	"melodex/store"
+)
+
+func newTestHandler(t *testing.T, sources ...scrapers.Source) *ScrapeHandler {
+	t.Helper()
+	registry := &scrapers.Registry{}
+	for _, src := range sources {
+		registry.Register(src)
+	}
+	return NewScrapeHandler(store.NewLocal(t.TempDir()), nil, nil, enrichment.New(), registry, config.Config{})
+}
+
+func fakeSource(collection string, songs []fs.Song, err error) scrapers.Source {
+	return scrapers.NewSource(scrapers.SourceInfo{
+		Name:       collection,
+		Target:     strings.ReplaceAll(collection, "_", "-"),
+		Collection: collection,
+		Cadence:    "0 * * * *",
+	}, func(ctx context.Context, deps scrapers.Deps) ([]fs.Song, error) {
+		return songs, err
+	})
+}
+
+func scrape(h *ScrapeHandler, target string) (*httptest.ResponseRecorder, fs.ScrapeJob) {
+	body := `{"target": "` + target + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/scrape?wait=true", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.Handle(w, req)
+
+	var job fs.ScrapeJob
+	json.NewDecoder(w.Body).Decode(&job)
+	return w, job
+}
+
+func TestHandle_WaitReportsEverySource(t *testing.T) {
+	h := newTestHandler(t,
+		fakeSource("good", []fs.Song{{Rank: 1, Artist: "SZA", Title: "Saturn"}}, nil),
+		fakeSource("bad", nil, errors.New("upstream down")),
+	)
+
+	w, job := scrape(h, "good")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for a single good source, got %d", w.Code)
+	}
+	if job.Status != fs.StatusSucceeded || len(job.Sources) != 1 || job.Sources[0].Scraped != 1 {
+		t.Errorf("Unexpected report: %+v", job)
+	}
+
+	w, job = scrape(h, "good")
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 when today's document exists, got %d", w.Code)
+	}
+
+	w, job = scrape(h, "bad")
+	if w.Code != http.StatusBadGateway || job.Sources[0].Error != "upstream down" {
+		t.Errorf("Expected 502 with the source error, got %d: %+v", w.Code, job)
+	}
+
+	stored, err := h.store.GetJob(context.Background(), job.ID)
+	if err != nil || stored.Status != fs.StatusFailed {
+		t.Errorf("Expected the failed job to be stored, got %+v, %v", stored, err)
+	}
+}
+
+func TestOverallStatus(t *testing.T) {
+	tests := []struct {
+		statuses []string
+		expected string
+	}{
+		{[]string{fs.StatusSucceeded, fs.StatusSkipped}, fs.StatusSucceeded},
+		{[]string{fs.StatusSucceeded, fs.StatusFailed}, fs.StatusPartial},
+		{[]string{fs.StatusFailed, fs.StatusFailed}, fs.StatusFailed},
+		{[]string{fs.StatusSucceeded, fs.StatusInterrupted}, fs.StatusInterrupted},
+	}
+
+	for _, tt := range tests {
+		var results []fs.SourceProgress
+		for _, s := range tt.statuses {
+			results = append(results, fs.SourceProgress{Status: s})
+		}
+		if got := overallStatus(results); got != tt.expected {
+			t.Errorf("overallStatus(%v) = %q, want %q", tt.statuses, got, tt.expected)
+		}
+	}
+}
+
+// countingStore counts job writes
+type countingStore struct {
+	store.Store
+	mu   sync.Mutex
+	jobs []fs.ScrapeJob
+}
+
+func (s *countingStore) SaveJob(ctx context.Context, job fs.ScrapeJob) error {
+	s.mu.Lock()
+	s.jobs = append(s.jobs, job)
+	s.mu.Unlock()
+	return s.Store.SaveJob(ctx, job)
+}
+
+func TestJobTracker_ThrottlesProgress(t *testing.T) {
+	st := &countingStore{Store: store.NewLocal(t.TempDir())}
+	tracker := &jobTracker{store: st, job: fs.ScrapeJob{
+		ID:      "job",
+		Sources: []fs.SourceProgress{{Source: "chart_a"}, {Source: "chart_b"}},
+	}}
+
+	// Only the first of a burst of progress updates is written
+	for i := 1; i <= 5; i++ {
+		tracker.update(fs.SourceProgress{Source: "chart_a", Status: fs.StatusRunning, Scraped: i})
+	}
+	if len(st.jobs) != 1 {
+		t.Fatalf("Expected one write for a burst of progress, got %d", len(st.jobs))
+	}
+
+	// A finished source is always written, with the progress of the others
+	tracker.update(fs.SourceProgress{Source: "chart_b", Status: fs.StatusSucceeded, FinishedAt: time.Now()})
+	if len(st.jobs) != 2 {
+		t.Fatalf("Expected a write when a source finishes, got %d", len(st.jobs))
+	}
+	if last := st.jobs[1]; last.Sources[0].Scraped != 5 || last.Sources[1].Status != fs.StatusSucceeded {
+		t.Errorf("Expected the latest progress to be written, got %+v", last.Sources)
+	}
+
+	job := tracker.finish()
+	if len(st.jobs) != 3 || st.jobs[2].Status != job.Status || job.FinishedAt.IsZero() {
+		t.Errorf("Expected the finished job to be written, got %d writes", len(st.jobs))
+	}
+}
+
+func TestHandle_WaitMapsErrorKinds(t *testing.T) {
+	blocked := &scrapers.StatusError{URL: "https://example.com", StatusCode: http.StatusForbidden}
+	h := newTestHandler(t,
+		fakeSource("blocked", nil, blocked),
+		fakeSource("changed", nil, fmt.Errorf("changed: %w", scrapers.ErrLayoutChanged)),
+	)
+
+	w, job := scrape(h, "blocked")
+	if w.Code != http.StatusServiceUnavailable || job.Sources[0].ErrorKind != errorKindBlocked {
+		t.Errorf("Expected 503 for a blocked source, got %d: %+v", w.Code, job)
+	}
+
+	w, job = scrape(h, "changed")
+	if w.Code != http.StatusBadGateway || job.Sources[0].ErrorKind != errorKindLayoutChanged {
+		t.Errorf("Expected 502 for a changed layout, got %d: %+v", w.Code, job)
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{&scrapers.StatusError{StatusCode: http.StatusTooManyRequests}, errorKindBlocked},
+		{&scrapers.StatusError{StatusCode: http.StatusInternalServerError}, errorKindUpstreamStatus},
+		{fmt.Errorf("fetch: %w", context.DeadlineExceeded), errorKindTimeout},
+		{errors.New("boom"), ""},
+	}
+
+	for _, tt := range tests {
+		if got := errorKind(tt.err); got != tt.expected {
+			t.Errorf("errorKind(%v) = %q, want %q", tt.err, got, tt.expected)
+		}
+	}
+}
+
+func TestShutdown_InterruptsRunningJobs(t *testing.T) {
+	started := make(chan struct{})
+	blocking := scrapers.NewSource(scrapers.SourceInfo{
+		Name:       "slow",
+		Target:     "slow",
+		Collection: "slow",
+	}, func(ctx context.Context, deps scrapers.Deps) ([]fs.Song, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	h := newTestHandler(t, blocking)
+
+	req := httptest.NewRequest(http.MethodPost, "/scrape", strings.NewReader(`{"target": "slow"}`))
+	w := httptest.NewRecorder()
+	h.Handle(w, req)
+
+	var resp ScrapeHandlerResp
+	json.NewDecoder(w.Body).Decode(&resp)
+	<-started
+
+	if err := h.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	job, err := h.store.GetJob(context.Background(), resp.JobID)
+	if err != nil || job.Status != fs.StatusInterrupted || job.Sources[0].Status != fs.StatusInterrupted {
+		t.Errorf("Expected an interrupted job after shutdown, got %+v, %v", job, err)
+	}
+
+	// No job starts once shut down
+	if w, _ := scrape(h, "slow"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 after shutdown, got %d", w.Code)
+	}
+	if _, err := h.RunScheduled(context.Background(), blocking); err == nil {
+		t.Error("Expected scheduled runs to be refused after shutdown")
+	}
+}
+
+func TestHandle_RecordsRuns(t *testing.T) {
+	h := newTestHandler(t,
+		fakeSource("good", []fs.Song{{Rank: 1, Artist: "SZA", Title: "Saturn"}}, nil),
+		fakeSource("bad", nil, errors.New("upstream down")),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/scrape?wait=true&trigger=backfill", strings.NewReader(`{"target": "good"}`))
+	h.Handle(httptest.NewRecorder(), req)
+	scrape(h, "bad")
+
+	req = httptest.NewRequest(http.MethodGet, "/runs?source=good&since=2000-01-01", nil)
+	w := httptest.NewRecorder()
+	h.HandleRuns(w, req)
+
+	var resp struct {
+		Runs []fs.ScrapeRun `json:"runs"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Runs) != 1 {
+		t.Fatalf("Expected one run for the good source, got %+v", resp.Runs)
+	}
+	run := resp.Runs[0]
+	if run.Trigger != fs.TriggerBackfill || run.Status != fs.StatusSucceeded || run.Scraped != 1 || run.Enriched != 1 || run.DocumentID == "" {
+		t.Errorf("Unexpected run record: %+v", run)
+	}
+
+	runs, _ := h.store.ListRuns(context.Background(), store.RunFilter{Source: "bad"})
+	if len(runs) != 1 || runs[0].Trigger != fs.TriggerManual || runs[0].Error != "upstream down" {
+		t.Errorf("Expected a failed manual run, got %+v", runs)
+	}
+}
+
+func TestHandle_DegradedScrapeKeepsGoodData(t *testing.T) {
+	broken := scrapers.NewSource(scrapers.SourceInfo{
+		Name:         "chart",
+		Target:       "chart",
+		Collection:   "chart",
+		Expectations: scrapers.Expectations{MinSongs: 2, ContiguousRanks: true},
+	}, func(ctx context.Context, deps scrapers.Deps) ([]fs.Song, error) {
+		return []fs.Song{{Rank: 0, Artist: "SZA", Title: "Saturn"}}, nil
+	})
+	h := newTestHandler(t, broken)
+
+	w, job := scrape(h, "chart")
+	if w.Code != http.StatusBadGateway || job.Sources[0].Status != fs.StatusDegraded || len(job.Sources[0].Problems) != 2 {
+		t.Fatalf("Expected a degraded run, got %d: %+v", w.Code, job)
+	}
+	if exists, _ := h.store.SnapshotExists(context.Background(), "chart", time.Now().Format("2006-01-02")); exists {
+		t.Error("Expected a degraded scrape not to be saved")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w = httptest.NewRecorder()
+	h.HandleHealth(w, req)
+
+	var health struct {
+		Status  string         `json:"status"`
+		Sources []SourceHealth `json:"sources"`
+	}
+	json.NewDecoder(w.Body).Decode(&health)
+	if health.Status != healthDegraded || health.Sources[0].Status != healthDegraded || health.Sources[0].ConsecutiveFailures != 1 {
+		t.Errorf("Expected the source to report degraded health, got %+v", health)
+	}
+}
+
+func TestHandle_RunAllRebuildsDiscovery(t *testing.T) {
+	h := newTestHandler(t,
+		fakeSource("chart_a", []fs.Song{{Rank: 1, Artist: "SZA", Title: "Saturn"}}, nil),
+		fakeSource("chart_b", []fs.Song{{Rank: 1, Artist: "SZA", Title: "Saturn"}, {Rank: 2, Artist: "Future", Title: "Type Shit"}}, nil),
+	)
+
+	// Single-source runs leave the feed alone
+	scrape(h, "chart-a")
+	today := time.Now().Format("2006-01-02")
+	if _, err := h.store.GetDiscovery(context.Background(), today); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected no feed after a single-source run, got %v", err)
+	}
+
+	// A run-all job, without podcasts as there is no Spotify client
+	ctx := context.Background()
+	tracker, err := h.newJob(ctx, fs.TriggerManual, "", false, h.sources.Scheduled(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.startJob()
+	h.runJob(ctx, tracker, h.sources.Scheduled(), false)
+
+	feed, err := h.store.GetDiscovery(ctx, today)
+	if err != nil {
+		t.Fatalf("Expected a feed after a run-all scrape: %v", err)
+	}
+	if len(feed.Sources) != 2 || len(feed.Tracks) != 2 || feed.Tracks[0].ScoreBreakdown == nil {
+		t.Errorf("Unexpected discovery feed: %+v", feed)
+	}
+}
+
+func TestRunScheduled_RebuildsDiscovery(t *testing.T) {
+	h := newTestHandler(t, fakeSource("chart_a", []fs.Song{{Rank: 1, Artist: "SZA", Title: "Saturn"}}, nil))
+	src, _ := h.sources.ByCollection("chart_a")
+
+	// Expired snapshots and feeds are cleaned up
+	ctx := context.Background()
+	old := time.Now().Add(-fs.DefaultTTL - 48*time.Hour).Format("2006-01-02")
+	h.store.SaveSnapshot(ctx, "chart_a", old, []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn"}})
+	h.store.SaveDiscovery(ctx, fs.Discovery{Date: old})
+
+	if _, err := h.RunScheduled(ctx, src); err != nil {
+		t.Fatalf("RunScheduled: %v", err)
+	}
+
+	feed, err := h.store.GetDiscovery(ctx, time.Now().Format("2006-01-02"))
+	if err != nil {
+		t.Fatalf("Expected a feed after a scheduled run: %v", err)
+	}
+	if len(feed.Tracks) != 1 {
+		t.Errorf("Unexpected discovery feed: %+v", feed)
+	}
+	if _, err := h.store.GetDiscovery(ctx, old); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected the expired feed to be cleaned up, got %v", err)
+	}
+	if ok, _ := h.store.SnapshotExists(ctx, "chart_a", old); ok {
+		t.Error("Expected the expired snapshot to be cleaned up")
+	}
+
+	// A run skipped as today's snapshot exists leaves the feed alone
+	today := time.Now().Format("2006-01-02")
+	h.store.SaveDiscovery(ctx, fs.Discovery{Date: today, Profile: "untouched"})
+	if job, _ := h.RunScheduled(ctx, src); job.Sources[0].Status != fs.StatusSkipped {
+		t.Fatalf("Expected the second run to be skipped, got %+v", job.Sources)
+	}
+	if feed, _ := h.store.GetDiscovery(ctx, today); feed.Profile != "untouched" {
+		t.Errorf("Expected no rebuild after a skipped run, got %+v", feed)
+	}
+}
+
+func TestHandle_StoresChartMovement(t *testing.T) {
+	h := newTestHandler(t, fakeSource("chart", []fs.Song{
+		{Rank: 1, Artist: "SZA", Title: "Saturn"},
+		{Rank: 2, Artist: "New", Title: "Song"},
+	}, nil))
+	ctx := context.Background()
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	h.store.SaveSnapshot(ctx, "chart", yesterday, []fs.Track{{Rank: 4, Artist: "SZA", Title: "Saturn", Movement: fs.MovementDebut}})
+
+	scrape(h, "chart")
+	snapshot, err := h.store.GetSnapshot(ctx, "chart", time.Now().Format("2006-01-02"))
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	saturn, song := snapshot.Tracks[0], snapshot.Tracks[1]
+	if saturn.Movement != fs.MovementUp || saturn.PreviousRank != 4 || saturn.RankChange != 3 {
+		t.Errorf("Expected the reused track to climb 3 places, got %+v", saturn)
+	}
+	if song.Movement != fs.MovementDebut {
+		t.Errorf("Expected a debut, got %+v", song)
+	}
+}
+
+func TestHandle_UpdatesCanonicalTracks(t *testing.T) {
+	h := newTestHandler(t, fakeSource("chart", []fs.Song{{Rank: 1, Artist: "SZA", Title: "Saturn"}}, nil))
+	ctx := context.Background()
+	today := time.Now().Format("2006-01-02")
+
+	scrape(h, "chart")
+	snapshot, err := h.store.GetSnapshot(ctx, "chart", today)
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	id := snapshot.Tracks[0].TrackID
+	if id == "" {
+		t.Fatal("Expected the daily document to reference the canonical track")
+	}
+
+	track, err := h.store.GetTrack(ctx, id)
+	if err != nil {
+		t.Fatalf("GetTrack: %v", err)
+	}
+	if track.Title != "Saturn" || len(track.Appearances) != 1 || track.Appearances[0] != (fs.TrackAppearance{Source: "chart", Date: today, Rank: 1}) {
+		t.Errorf("Unexpected canonical track %+v", track)
+	}
+	credits := snapshot.Tracks[0].Artists
+	if len(credits) != 1 || credits[0].ArtistID == "" {
+		t.Fatalf("Expected the daily document to credit its artist, got %+v", credits)
+	}
+	if artist, err := h.store.GetArtist(ctx, credits[0].ArtistID); err != nil || artist.Name != "SZA" || len(artist.Tracks) != 1 || artist.Tracks[0] != id {
+		t.Errorf("Unexpected artist %+v, %v", artist, err)
+	}
+}
+
+func TestHandle_DropsUnidentifiedTracks(t *testing.T) {
+	src := scrapers.NewSource(scrapers.SourceInfo{
+		Name:       "chart",
+		Target:     "chart",
+		Collection: "chart",
+		RequireID:  true,
+	}, func(ctx context.Context, deps scrapers.Deps) ([]fs.Song, error) {
+		return []fs.Song{
+			{Rank: 1, Artist: "SZA", Title: "Saturn", ISRC: "USRC12400001"},
+			{Rank: 2, Artist: "Nobody", Title: "Unknown"},
+		}, nil
+	})
+	h := newTestHandler(t, src)
+
+	if w, _ := scrape(h, "chart"); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	snapshot, err := h.store.GetSnapshot(context.Background(), "chart", time.Now().Format("2006-01-02"))
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	if len(snapshot.Tracks) != 1 || snapshot.Tracks[0].ISRC != "USRC12400001" {
+		t.Errorf("Expected only the identified track, got %+v", snapshot.Tracks)
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"melodex/catalog"
+	"melodex/enrichment"
+	fs "melodex/firestore"
+	"melodex/history"
+	"melodex/scrapers"
+)
+
+// errDegraded is reported for sources whose scrape failed their expectations
+var errDegraded = errors.New("scrape failed expectations")
+
+// progressFunc receives a source's result every time it changes
+type progressFunc func(result fs.SourceProgress)
+
+// runSource scrapes a registered source, enriches its songs and saves
+// them as today's document in the source's collection. Intermediate
+// results are published through progress; the final one is returned.
+func (h *ScrapeHandler) runSource(ctx context.Context, src scrapers.Source, debugMode bool, progress progressFunc) fs.SourceProgress {
+	collection := src.Collection()
+	result := fs.SourceProgress{
+		Source:    collection,
+		Status:    fs.StatusRunning,
+		StartedAt: time.Now(),
+	}
+	progress(result)
+
+	finish := func(status string, err error) fs.SourceProgress {
+		result.Status = status
+		if err != nil {
+			result.Error = err.Error()
+			result.ErrorKind = errorKind(err)
+		}
+		result.FinishedAt = time.Now()
+		result.DurationMs = result.FinishedAt.Sub(result.StartedAt).Milliseconds()
+		progress(result)
+		return result
+	}
+
+	today := time.Now().Format("2006-01-02")
+	log.Printf("Checking if document for today (%s) exists in %s", today, collection)
+
+	// Skip DB check in debug mode
+	if !debugMode {
+		// Check if today's document exists
+		exists, err := h.store.SnapshotExists(ctx, collection, today)
+		if err == nil && exists {
+			log.Printf("Data for today (%s) already exists in %s", today, collection)
+			result.DocumentID = today
+			return finish(fs.StatusSkipped, errAlreadyExists)
+		} else if err != nil {
+			log.Printf("Error checking today's document existence: %v", err)
+		}
+	} else {
+		log.Printf("Debug mode: Skipping database existence check")
+	}
+
+	// Load the kept snapshots for metadata reuse, size checks and chart
+	// movement; the latest is the previous snapshot
+	var chart *history.Chart
+	var previousTracks []fs.Track
+	if !debugMode {
+		yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+		c, err := history.Load(ctx, h.store, collection, history.From("", h.retention), yesterday)
+		if err != nil {
+			log.Printf("Error loading %s snapshots: %v", collection, err)
+		} else if dates := c.Dates(); len(dates) > 0 {
+			chart = c
+			previous := dates[len(dates)-1]
+			previousTracks, _ = c.Snapshot(previous)
+			log.Printf("Loaded %d tracks from the previous %s snapshot (%s)", len(previousTracks), collection, previous)
+		} else {
+			chart = c
+			log.Printf("No previous %s snapshot found", collection)
+		}
+	} else {
+		log.Printf("Debug mode: Skipping previous snapshot fetch")
+	}
+
+	log.Printf("Scraping %s", src.Name())
+	songs, err := src.Fetch(ctx)
+	if ctx.Err() != nil {
+		log.Printf("%s scraping interrupted: %v", src.Name(), ctx.Err())
+		return finish(fs.StatusInterrupted, ctx.Err())
+	}
+	if err != nil {
+		log.Printf("%s scraping failed: %v", src.Name(), err)
+		return finish(fs.StatusFailed, err)
+	}
+	result.Scraped = len(songs)
+	progress(result)
+
+	// Never let a broken scrape replace good data
+	if problems := src.Expectations().Validate(songs, len(previousTracks)); len(problems) > 0 {
+		result.Problems = problems
+		log.Printf("ALERT: %s looks broken, not saving: %s", src.Name(), strings.Join(problems, "; "))
+		return finish(fs.StatusDegraded, fmt.Errorf("%w: %s", errDegraded, strings.Join(problems, "; ")))
+	}
+
+	tracks, _ := h.enricher.Enrich(ctx, collection, songs, previousTracks, func(stats enrichment.Stats) {
+		result.Enriched = stats.Enriched
+		result.Reused = stats.Reused
+		result.Cached = stats.Cached
+		result.Failed = stats.Failed
+		result.Misses = stats.Misses
+		result.Errors = stats.Errors
+		progress(result)
+	})
+
+	// Keep the counts of an interrupted run, but don't save a partial snapshot
+	// that would stop the next run from scraping today
+	if ctx.Err() != nil {
+		log.Printf("%s enrichment interrupted after %d songs: %v", src.Name(), result.Enriched+result.Reused+result.Cached, ctx.Err())
+		return finish(fs.StatusInterrupted, ctx.Err())
+	}
+
+	if src.RequiresID() {
+		tracks = identified(src, tracks)
+	}
+	if chart != nil {
+		chart.Annotate(tracks)
+	}
+	catalog.Assign(tracks)
+
+	// Save today's data to Firestore
+	if !debugMode {
+		if err := h.store.SaveSnapshot(ctx, collection, today, tracks); err != nil {
+			log.Printf("Failed to update Firestore: %v", err)
+			return finish(fs.StatusFailed, err)
+		}
+		result.DocumentID = today
+		log.Printf("Successfully created %s document for today (%s)", collection, today)
+
+		// The snapshot is already saved, so a failure here only leaves its
+		// appearances out of the canonical records
+		if err := catalog.Update(ctx, h.store, collection, today, tracks); err != nil {
+			log.Printf("Error updating canonical tracks from %s: %v", collection, err)
+		}
+	} else {
+		result.Tracks = tracks
+		log.Printf("Debug mode: Skipping database save")
+	}
+
+	return finish(fs.StatusSucceeded, nil)
+}
+
+// identified drops the tracks that have neither an ISRC nor a MusicBrainz
+// ID, so they never reach the feed or the catalog
+func identified(src scrapers.Source, tracks []fs.Track) []fs.Track {
+	kept := tracks[:0]
+	for _, track := range tracks {
+		if track.ISRC == "" && track.MBID == "" {
+			log.Printf("Dropping unidentified %s track: %s by %s", src.Name(), track.Title, track.Artist)
+			continue
+		}
+		kept = append(kept, track)
+	}
+	return kept
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"melodex/catalog"
+	"melodex/config"
+	fs "melodex/firestore"
+	"melodex/scrapers"
+	"melodex/store"
+)
+
+// TracksHandler serves stored daily snapshots, so clients read typed tracks
+// instead of depending on the collection layout.
+type TracksHandler struct {
+	store   store.Store
+	sources *scrapers.Registry
+
+	// Scoring profiles for /discover, and the one used without ?profile=
+	profiles config.ScoringProfiles
+	profile  string
+
+	// How far back chart history reaches, matching TTL cleanup
+	retention time.Duration
+}
+
+func NewTracksHandler(st store.Store, sources *scrapers.Registry, cfg config.Config) *TracksHandler {
+	return &TracksHandler{
+		store:    st,
+		sources:  sources,
+		profiles: cfg.ScoringProfiles,
+		profile:  cfg.ScoringProfile,
+
+		retention: historyRetention(cfg),
+	}
+}
+
+// HandleTracks returns the snapshot of ?source= (a collection or a scrape
+// target) on ?date=, or on its latest stored date when date is omitted.
+func (h *TracksHandler) HandleTracks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	src, ok := h.source(query.Get("source"))
+	if !ok {
+		http.Error(w, "Unknown source: "+query.Get("source"), http.StatusNotFound)
+		return
+	}
+
+	date := query.Get("date")
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			http.Error(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	} else {
+		latest, err := h.latestDate(r.Context(), src.Collection())
+		if err != nil {
+			h.storeError(w, src, err)
+			return
+		}
+		date = latest
+	}
+
+	snapshot, err := h.store.GetSnapshot(r.Context(), src.Collection(), date)
+	if err != nil {
+		h.storeError(w, src, err)
+		return
+	}
+
+	tracks := snapshot.Tracks
+	if tracks == nil {
+		tracks = []fs.Track{}
+	}
+	writeCached(w, r, snapshot.UpdatedAt, map[string]interface{}{
+		"source": src.Collection(),
+		"date":   date,
+		"count":  len(tracks),
+		"tracks": tracks,
+	})
+}
+
+// HandleDates lists the stored snapshot dates of a source in ascending order
+func (h *TracksHandler) HandleDates(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["source"]
+	src, ok := h.source(name)
+	if !ok {
+		http.Error(w, "Unknown source: "+name, http.StatusNotFound)
+		return
+	}
+
+	dates, err := h.store.ListSnapshotDates(r.Context(), src.Collection())
+	if err != nil {
+		h.storeError(w, src, err)
+		return
+	}
+	if dates == nil {
+		dates = []string{}
+	}
+
+	writeCached(w, r, time.Time{}, map[string]interface{}{
+		"source": src.Collection(),
+		"count":  len(dates),
+		"dates":  dates,
+	})
+}
+
+// HandleTrack returns the canonical record of a track by its melodex ID,
+// the trackID of its entries in the daily documents. IDs of records merged
+// into another return that record.
+func (h *TracksHandler) HandleTrack(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	track, err := catalog.GetTrack(r.Context(), h.store, id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Unknown track: "+id, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read track: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error reading track %s: %v", id, err)
+		return
+	}
+	writeCached(w, r, track.UpdatedAt, track)
+}
+
+// HandleArtist returns an artist by its melodex ID, the artistID of its
+// credits on tracks, with the IDs of the canonical tracks it is credited on.
+// IDs of records merged into another return that record.
+func (h *TracksHandler) HandleArtist(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	artist, err := catalog.GetArtist(r.Context(), h.store, id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Unknown artist: "+id, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read artist: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error reading artist %s: %v", id, err)
+		return
+	}
+	writeCached(w, r, artist.UpdatedAt, artist)
+}
+
+// source looks a source up by collection first, then by scrape target
+func (h *TracksHandler) source(name string) (scrapers.Source, bool) {
+	if src, ok := h.sources.ByCollection(name); ok {
+		return src, true
+	}
+	return h.sources.ByTarget(name)
+}
+
+// latestDate returns the most recent stored date of a collection
+func (h *TracksHandler) latestDate(ctx context.Context, collection string) (string, error) {
+	dates, err := h.store.ListSnapshotDates(ctx, collection)
+	if err != nil {
+		return "", err
+	}
+	if len(dates) == 0 {
+		return "", store.ErrNotFound
+	}
+	return dates[len(dates)-1], nil
+}
+
+func (h *TracksHandler) storeError(w http.ResponseWriter, src scrapers.Source, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "No snapshot found for "+src.Collection(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Failed to read snapshots: "+err.Error(), http.StatusInternalServerError)
+	log.Printf("Error reading %s snapshots: %v", src.Collection(), err)
+}
+
+// writeCached writes v as JSON with a strong ETag of the body and, when
+// modified is set, a Last-Modified header. Conditional requests matching
+// either get a 304 without a body. Range requests get the full body, as
+// slices of a JSON document are useless.
+func writeCached(w http.ResponseWriter, r *http.Request, modified time.Time, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when it is
+// absent, as RFC 9110 orders them
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	// Last-Modified only has second precision
+	return !modified.Truncate(time.Second).After(since)
+}
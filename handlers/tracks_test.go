@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"melodex/config"
+	fs "melodex/firestore"
+	"melodex/history"
+	"melodex/scrapers"
+	"melodex/store"
+)
+
+func newTracksRouter(t *testing.T) (*mux.Router, store.Store) {
+	t.Helper()
+	st := store.NewLocal(t.TempDir())
+	registry := &scrapers.Registry{}
+	registry.Register(fakeSource("billboard", nil, nil))
+	registry.Register(fakeSource("hnhh", nil, nil))
+
+	h := NewTracksHandler(st, registry, config.Config{})
+	r := mux.NewRouter()
+	r.HandleFunc("/tracks", h.HandleTracks).Methods("GET")
+	r.HandleFunc("/tracks/{id}", h.HandleTrack).Methods("GET")
+	r.HandleFunc("/artists/{id}", h.HandleArtist).Methods("GET")
+	r.HandleFunc("/tracks/{isrc}/history", h.HandleHistory).Methods("GET")
+	r.HandleFunc("/sources/{source}/dates", h.HandleDates).Methods("GET")
+	r.HandleFunc("/sources/{source}/diff", h.HandleDiff).Methods("GET")
+	r.HandleFunc("/discover", h.HandleDiscover).Methods("GET")
+	return r, st
+}
+
+func get(r http.Handler, url string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleTracks(t *testing.T) {
+	r, st := newTracksRouter(t)
+	ctx := context.Background()
+	st.SaveSnapshot(ctx, "billboard", "2024-02-03", []fs.Track{{Rank: 1, Artist: "Jack Harlow", Title: "Lovin On Me"}})
+	st.SaveSnapshot(ctx, "billboard", "2024-02-04", []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn"}})
+
+	w := get(r, "/tracks?source=billboard&date=2024-02-03", nil)
+	var resp struct {
+		Date   string     `json:"date"`
+		Count  int        `json:"count"`
+		Tracks []fs.Track `json:"tracks"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with JSON, got %d (%v)", w.Code, err)
+	}
+	if resp.Count != 1 || resp.Tracks[0].Title != "Lovin On Me" {
+		t.Errorf("Unexpected tracks for 2024-02-03: %+v", resp)
+	}
+
+	// Without a date the latest snapshot is returned, by target as well
+	w = get(r, "/tracks?source=billboard", nil)
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Date != "2024-02-04" || resp.Tracks[0].Title != "Saturn" {
+		t.Errorf("Expected the latest snapshot, got %+v", resp)
+	}
+
+	for url, want := range map[string]int{
+		"/tracks?source=billboard&date=2024-01-01": http.StatusNotFound,
+		"/tracks?source=billboard&date=yesterday":  http.StatusBadRequest,
+		"/tracks?source=unknown":                   http.StatusNotFound,
+	} {
+		if w := get(r, url, nil); w.Code != want {
+			t.Errorf("GET %s: expected %d, got %d", url, want, w.Code)
+		}
+	}
+}
+
+func TestHandleTracks_ConditionalRequests(t *testing.T) {
+	r, st := newTracksRouter(t)
+	st.SaveSnapshot(context.Background(), "billboard", "2024-02-04", []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn"}})
+
+	w := get(r, "/tracks?source=billboard&date=2024-02-04", nil)
+	etag, modified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if etag == "" || modified == "" {
+		t.Fatalf("Expected ETag and Last-Modified, got %v", w.Header())
+	}
+
+	w = get(r, "/tracks?source=billboard&date=2024-02-04", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 for a matching ETag, got %d", w.Code)
+	}
+	w = get(r, "/tracks?source=billboard&date=2024-02-04", http.Header{"If-Modified-Since": {modified}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for an unchanged snapshot, got %d", w.Code)
+	}
+	w = get(r, "/tracks?source=billboard&date=2024-02-04", http.Header{"If-None-Match": {`"stale"`}})
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a stale ETag, got %d", w.Code)
+	}
+
+	full := get(r, "/tracks?source=billboard&date=2024-02-04", nil).Body.String()
+	w = get(r, "/tracks?source=billboard&date=2024-02-04", http.Header{"Range": {"bytes=0-9"}})
+	if w.Code != http.StatusOK || w.Body.String() != full {
+		t.Errorf("Expected the full body for a range request, got %d: %q", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleDates(t *testing.T) {
+	r, st := newTracksRouter(t)
+	ctx := context.Background()
+
+	w := get(r, "/sources/billboard/dates", nil)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == "" {
+		t.Errorf("Expected 200 with an ETag for a source without snapshots, got %d", w.Code)
+	}
+
+	st.SaveSnapshot(ctx, "billboard", "2024-02-04", nil)
+	st.SaveSnapshot(ctx, "billboard", "2024-02-03", nil)
+	w = get(r, "/sources/billboard/dates", nil)
+	var resp struct {
+		Dates []string `json:"dates"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Dates) != 2 || resp.Dates[0] != "2024-02-03" {
+		t.Errorf("Expected ascending dates, got %v", resp.Dates)
+	}
+
+	if w := get(r, "/sources/unknown/dates", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown source, got %d", w.Code)
+	}
+}
+
+func TestHandleHistory(t *testing.T) {
+	r, st := newTracksRouter(t)
+	ctx := context.Background()
+	day := func(ago int) string { return time.Now().AddDate(0, 0, -ago).Format("2006-01-02") }
+	saturn := fs.Track{Rank: 3, Artist: "SZA", Title: "Saturn", ISRC: "USRC12400001"}
+	st.SaveSnapshot(ctx, "billboard", day(30), []fs.Track{{Rank: 2, Artist: "SZA", Title: "Saturn", ISRC: "USRC12400001"}})
+	st.SaveSnapshot(ctx, "billboard", day(2), []fs.Track{saturn})
+	saturn.Rank = 1
+	st.SaveSnapshot(ctx, "billboard", day(1), []fs.Track{saturn})
+	st.SaveSnapshot(ctx, "hnhh", day(1), []fs.Track{{Rank: 1, Artist: "Future", Title: "Type Shit"}})
+
+	w := get(r, "/tracks/USRC12400001/history", nil)
+	var resp struct {
+		Count   int               `json:"count"`
+		History []history.History `json:"history"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with JSON, got %d (%v)", w.Code, err)
+	}
+	if resp.Count != 1 || resp.History[0].Source != "billboard" || resp.History[0].PeakRank != 1 || resp.History[0].PreviousRank != 3 {
+		t.Errorf("Unexpected history: %+v", resp)
+	}
+	if h := resp.History[0]; h.FirstSeen != day(2) {
+		t.Errorf("Expected history to start in the retention window, got %+v", h)
+	}
+
+	// An explicit range reaches the snapshots in it
+	w = get(r, "/tracks/USRC12400001/history?from="+day(40)+"&to="+day(20), nil)
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || resp.History[0].LastSeen != day(30) || resp.History[0].Rank != 2 {
+		t.Errorf("Expected the snapshot in range, got %d %+v", w.Code, resp)
+	}
+
+	for url, want := range map[string]int{
+		"/tracks/USRC12400001/history?source=hnhh":    http.StatusNotFound,
+		"/tracks/USRC12400001/history?source=unknown": http.StatusNotFound,
+		"/tracks/UNKNOWN/history":                     http.StatusNotFound,
+		"/tracks/USRC12400001/history?from=yesterday": http.StatusBadRequest,
+	} {
+		if w := get(r, url, nil); w.Code != want {
+			t.Errorf("GET %s: expected %d, got %d", url, want, w.Code)
+		}
+	}
+}
+
+func TestHandleDiff(t *testing.T) {
+	r, st := newTracksRouter(t)
+	ctx := context.Background()
+	day := func(ago int) string { return time.Now().AddDate(0, 0, -ago).Format("2006-01-02") }
+	st.SaveSnapshot(ctx, "hnhh", day(2), []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn"}, {Rank: 2, Artist: "Gone", Title: "Away"}})
+	st.SaveSnapshot(ctx, "hnhh", day(1), []fs.Track{{Rank: 1, Artist: "New", Title: "Song"}, {Rank: 2, Artist: "SZA", Title: "Saturn"}})
+
+	w := get(r, "/sources/hnhh/diff", nil)
+	var resp struct {
+		From    string           `json:"from"`
+		To      string           `json:"to"`
+		Summary map[string]int   `json:"summary"`
+		Changes []history.Change `json:"changes"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with JSON, got %d (%v)", w.Code, err)
+	}
+	if resp.From != day(2) || resp.To != day(1) || len(resp.Changes) != 3 {
+		t.Errorf("Expected the latest two snapshots to be compared, got %+v", resp)
+	}
+	if resp.Summary[fs.MovementDebut] != 1 || resp.Summary[fs.MovementDown] != 1 || resp.Summary[fs.MovementDropped] != 1 {
+		t.Errorf("Unexpected summary: %v", resp.Summary)
+	}
+
+	for url, want := range map[string]int{
+		"/sources/hnhh/diff?from=" + day(3) + "&to=" + day(1): http.StatusNotFound,
+		"/sources/hnhh/diff?from=" + day(1) + "&to=" + day(2): http.StatusBadRequest,
+		"/sources/hnhh/diff?to=tomorrow":                      http.StatusBadRequest,
+		"/sources/billboard/diff":                             http.StatusBadRequest,
+		"/sources/unknown/diff":                               http.StatusNotFound,
+	} {
+		if w := get(r, url, nil); w.Code != want {
+			t.Errorf("GET %s: expected %d, got %d", url, want, w.Code)
+		}
+	}
+}
+
+func TestHandleTrack(t *testing.T) {
+	r, st := newTracksRouter(t)
+	st.SaveTrack(context.Background(), fs.CanonicalTrack{
+		ID: "mx0123456789abcdef", Artist: "SZA", Title: "Saturn", Sources: []string{"billboard"},
+		Appearances: []fs.TrackAppearance{{Source: "billboard", Date: "2024-02-04", Rank: 1}},
+	})
+
+	w := get(r, "/tracks/mx0123456789abcdef", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var track fs.CanonicalTrack
+	if err := json.NewDecoder(w.Body).Decode(&track); err != nil {
+		t.Fatal(err)
+	}
+	if track.Title != "Saturn" || len(track.Appearances) != 1 {
+		t.Errorf("Unexpected track %+v", track)
+	}
+
+	if w := get(r, "/tracks/mxunknown", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown track, got %d", w.Code)
+	}
+}
+
+func TestHandleArtist(t *testing.T) {
+	r, st := newTracksRouter(t)
+	st.SaveArtist(context.Background(), fs.Artist{
+		ID: "mxa0123456789abcdef", Name: "SZA", SpotifyID: "sza",
+		Sources: []string{"billboard"}, Tracks: []string{"mx0123456789abcdef"},
+	})
+
+	w := get(r, "/artists/mxa0123456789abcdef", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var artist fs.Artist
+	if err := json.NewDecoder(w.Body).Decode(&artist); err != nil {
+		t.Fatal(err)
+	}
+	if artist.Name != "SZA" || len(artist.Tracks) != 1 {
+		t.Errorf("Unexpected artist %+v", artist)
+	}
+
+	if w := get(r, "/artists/mxaunknown", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown artist, got %d", w.Code)
+	}
+}
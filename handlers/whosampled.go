@@ -5,24 +5,23 @@ import (
 	"log"
 	"net/http"
 
-	"cloud.google.com/go/firestore"
-
 	"melodex/scrapers"
 	spot "melodex/spotify"
+	"melodex/store"
 )
 
 type WhoSampledHandler struct {
-	db *firestore.Client
-	sp *spot.SpotifyClient
+	store store.Store
+	sp    *spot.SpotifyClient
 }
 
 func NewWhoSampledHandler(
-	db *firestore.Client,
+	st store.Store,
 	sp *spot.SpotifyClient,
 ) *WhoSampledHandler {
 	return &WhoSampledHandler{
-		db: db,
-		sp: sp,
+		store: st,
+		sp:    sp,
 	}
 }
 
@@ -45,9 +44,9 @@ func (h *WhoSampledHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	q := req.Query
 	log.Printf("Query: %v", q)
 
-	_, err := scrapers.ScrapeWhoSampled(w, q)
+	_, err := scrapers.ScrapeWhoSampled(r.Context(), q)
 	if err != nil {
-		http.Error(w, "Failed to scrape Billboard Hot 100: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to scrape WhoSampled: "+err.Error(), errorKindHTTPStatus(errorKind(err)))
 		log.Printf("Scraping failed: %v", err)
 		return
 	}
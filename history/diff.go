@@ -0,0 +1,119 @@
+package history
+
+import (
+	"fmt"
+
+	fs "melodex/firestore"
+	"melodex/scoring"
+	"melodex/store"
+)
+
+// Change is the movement of a track between two snapshots of a chart
+type Change struct {
+	Artist       string `json:"artist"`
+	Title        string `json:"title"`
+	ISRC         string `json:"isrc,omitempty"`
+	Movement     string `json:"movement"`
+	Rank         int    `json:"rank,omitempty"`         // Rank in the later snapshot, 0 when dropped
+	PreviousRank int    `json:"previousRank,omitempty"` // Rank in the earlier snapshot, 0 when new
+	RankChange   int    `json:"rankChange,omitempty"`   // Places climbed, negative when falling
+}
+
+// Diff classifies every track of the snapshots of from and to: the tracks
+// of to in chart order, followed by the tracks that dropped out. A track
+// missing from from is a re-entry if it was seen before from.
+func (c *Chart) Diff(from, to string) ([]Change, error) {
+	fromTracks, ok := c.snapshots[from]
+	if !ok {
+		return nil, fmt.Errorf("%s snapshot of %s: %w", c.Source, from, store.ErrNotFound)
+	}
+	toTracks, ok := c.snapshots[to]
+	if !ok {
+		return nil, fmt.Errorf("%s snapshot of %s: %w", c.Source, to, store.ErrNotFound)
+	}
+
+	changes := make([]Change, 0, len(toTracks))
+	for _, track := range toTracks {
+		movement, previous := c.movement(track, from)
+		changes = append(changes, Change{
+			Artist:       track.Artist,
+			Title:        track.Title,
+			ISRC:         track.ISRC,
+			Movement:     movement,
+			Rank:         track.Rank,
+			PreviousRank: previous,
+			RankChange:   rankChange(previous, track.Rank),
+		})
+	}
+
+	for _, track := range fromTracks {
+		if _, ok := c.ranks(track)[to]; ok {
+			continue
+		}
+		changes = append(changes, Change{
+			Artist:       track.Artist,
+			Title:        track.Title,
+			ISRC:         track.ISRC,
+			Movement:     fs.MovementDropped,
+			PreviousRank: track.Rank,
+		})
+	}
+	return changes, nil
+}
+
+// Annotate sets the movement fields of tracks, a new snapshot not yet in
+// the chart, against the chart's latest snapshot. Without a previous
+// snapshot there is nothing to move against, and the fields are cleared.
+func (c *Chart) Annotate(tracks []fs.Track) {
+	var latest string
+	if len(c.dates) > 0 {
+		latest = c.dates[len(c.dates)-1]
+	}
+
+	for i := range tracks {
+		track := &tracks[i]
+		track.Movement, track.PreviousRank, track.RankChange = "", 0, 0
+		if latest == "" {
+			continue
+		}
+		track.Movement, track.PreviousRank = c.movement(*track, latest)
+		track.RankChange = rankChange(track.PreviousRank, track.Rank)
+	}
+}
+
+// movement classifies track, at its own rank, against the snapshot of from
+func (c *Chart) movement(track fs.Track, from string) (string, int) {
+	ranks := c.ranks(track)
+	previous, ok := ranks[from]
+	switch {
+	case ok && track.Rank < previous:
+		return fs.MovementUp, previous
+	case ok && track.Rank > previous:
+		return fs.MovementDown, previous
+	case ok:
+		return fs.MovementUnchanged, previous
+	}
+
+	for date := range ranks {
+		if date < from {
+			return fs.MovementReEntry, 0
+		}
+	}
+	return fs.MovementDebut, 0
+}
+
+// ranks returns the rank of track on every date it appears on
+func (c *Chart) ranks(track fs.Track) map[string]int {
+	keys := map[string]bool{scoring.TrackKey(track.Artist, track.Title): true}
+	for key := range c.keysISRC[track.ISRC] {
+		keys[key] = true
+	}
+	return c.ranksOf(keys)
+}
+
+func rankChange(previous, rank int) int {
+	if previous == 0 {
+		return 0
+	}
+	return previous - rank
+}
@@ -0,0 +1,83 @@
+package history
+
+import (
+	"context"
+	"testing"
+
+	fs "melodex/firestore"
+	"melodex/store"
+)
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+	st.SaveSnapshot(ctx, "hnhh", "2024-02-01", []fs.Track{
+		{Rank: 1, Artist: "Drake", Title: "Old Hit"},
+	})
+	st.SaveSnapshot(ctx, "hnhh", "2024-02-02", []fs.Track{
+		{Rank: 1, Artist: "SZA", Title: "Saturn"},
+		{Rank: 2, Artist: "Future", Title: "Type Shit", ISRC: "USRC1"},
+		{Rank: 3, Artist: "Tate McRae", Title: "greedy"},
+		{Rank: 4, Artist: "Gone", Title: "Away"},
+	})
+	st.SaveSnapshot(ctx, "hnhh", "2024-02-03", []fs.Track{
+		{Rank: 1, Artist: "Future", Title: "Type Shit (Explicit)", ISRC: "USRC1"},
+		{Rank: 2, Artist: "SZA", Title: "Saturn"},
+		{Rank: 3, Artist: "Tate McRae", Title: "greedy"},
+		{Rank: 4, Artist: "Drake", Title: "Old Hit"},
+		{Rank: 5, Artist: "New", Title: "Song"},
+	})
+
+	chart, err := Load(ctx, st, "hnhh", "", "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	changes, err := chart.Diff("2024-02-02", "2024-02-03")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+
+	want := []Change{
+		{Artist: "Future", Title: "Type Shit (Explicit)", ISRC: "USRC1", Movement: fs.MovementUp, Rank: 1, PreviousRank: 2, RankChange: 1},
+		{Artist: "SZA", Title: "Saturn", Movement: fs.MovementDown, Rank: 2, PreviousRank: 1, RankChange: -1},
+		{Artist: "Tate McRae", Title: "greedy", Movement: fs.MovementUnchanged, Rank: 3, PreviousRank: 3},
+		{Artist: "Drake", Title: "Old Hit", Movement: fs.MovementReEntry, Rank: 4},
+		{Artist: "New", Title: "Song", Movement: fs.MovementDebut, Rank: 5},
+		{Artist: "Gone", Title: "Away", Movement: fs.MovementDropped, PreviousRank: 4},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Change %d: got %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+
+	if _, err := chart.Diff("2024-01-01", "2024-02-03"); err == nil {
+		t.Error("Expected an error for a missing snapshot")
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+
+	empty, _ := Load(ctx, st, "hnhh", "", "")
+	tracks := []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn", Movement: fs.MovementUp, PreviousRank: 3, RankChange: 2}}
+	empty.Annotate(tracks)
+	if tracks[0].Movement != "" || tracks[0].PreviousRank != 0 || tracks[0].RankChange != 0 {
+		t.Errorf("Expected stale movement to be cleared without a previous snapshot, got %+v", tracks[0])
+	}
+
+	st.SaveSnapshot(ctx, "hnhh", "2024-02-02", []fs.Track{{Rank: 3, Artist: "SZA", Title: "Saturn"}})
+	chart, _ := Load(ctx, st, "hnhh", "", "")
+	tracks = []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn"}, {Rank: 2, Artist: "New", Title: "Song"}}
+	chart.Annotate(tracks)
+	if tracks[0].Movement != fs.MovementUp || tracks[0].PreviousRank != 3 || tracks[0].RankChange != 2 {
+		t.Errorf("Expected a climb of 2, got %+v", tracks[0])
+	}
+	if tracks[1].Movement != fs.MovementDebut {
+		t.Errorf("Expected a debut, got %+v", tracks[1])
+	}
+}
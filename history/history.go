@@ -0,0 +1,197 @@
+package history
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	fs "melodex/firestore"
+	"melodex/scoring"
+	"melodex/store"
+)
+
+// History is the chart run of a track on one source, computed from the
+// source's stored snapshots.
+type History struct {
+	Source       string       `json:"source"`
+	FirstSeen    string       `json:"firstSeen"`
+	LastSeen     string       `json:"lastSeen"`
+	OnChart      bool         `json:"onChart"`     // Present in the latest snapshot
+	Streak       int          `json:"streak"`      // Consecutive snapshots present, up to LastSeen
+	StreakSince  string       `json:"streakSince"` // First date of that streak
+	DaysOnChart  int          `json:"daysOnChart"` // Days from StreakSince to LastSeen, inclusive
+	WeeksOnChart int          `json:"weeksOnChart"`
+	Rank         int          `json:"rank"` // Rank on LastSeen
+	PeakRank     int          `json:"peakRank"`
+	PreviousRank int          `json:"previousRank,omitempty"` // Rank in the snapshot before LastSeen, 0 if absent
+	Appearances  []Appearance `json:"appearances"`
+}
+
+// Appearance is a track's rank in one snapshot
+type Appearance struct {
+	Date string `json:"date"`
+	Rank int    `json:"rank"`
+}
+
+// Chart indexes the stored snapshots of a source for history lookups.
+// Tracks are matched by ISRC, or by their deduplication key when a snapshot
+// is missing the ISRC.
+type Chart struct {
+	Source    string
+	dates     []string
+	snapshots map[string][]fs.Track
+	byKey     map[string]map[string]int // key -> date -> rank
+	keysISRC  map[string]map[string]bool
+}
+
+// Load reads the snapshots of collection dated from through until, inclusive.
+// An empty from or until leaves that end open. Snapshots older than the
+// history retention are deleted by TTL cleanup, so history only covers the
+// kept ones.
+func Load(ctx context.Context, st store.Store, collection, from, until string) (*Chart, error) {
+	dates, err := st.ListSnapshotDates(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Chart{
+		Source:    collection,
+		snapshots: make(map[string][]fs.Track),
+		byKey:     make(map[string]map[string]int),
+		keysISRC:  make(map[string]map[string]bool),
+	}
+	for _, date := range dates {
+		if date < from {
+			continue
+		}
+		if until != "" && date > until {
+			break
+		}
+		snapshot, err := st.GetSnapshot(ctx, collection, date)
+		if err != nil {
+			return nil, err
+		}
+		c.add(date, snapshot.Tracks)
+	}
+	return c, nil
+}
+
+// From returns the first date within retention of until, or of today when
+// until is empty, for use as the start of Load.
+func From(until string, retention time.Duration) string {
+	end := time.Now()
+	if day, err := time.Parse("2006-01-02", until); err == nil {
+		end = day
+	}
+	return end.Add(-retention).Format("2006-01-02")
+}
+
+func (c *Chart) add(date string, tracks []fs.Track) {
+	c.dates = append(c.dates, date)
+	c.snapshots[date] = tracks
+
+	for _, track := range tracks {
+		key := scoring.TrackKey(track.Artist, track.Title)
+		if c.byKey[key] == nil {
+			c.byKey[key] = make(map[string]int)
+		}
+		if _, seen := c.byKey[key][date]; !seen {
+			c.byKey[key][date] = track.Rank
+		}
+
+		if track.ISRC != "" {
+			if c.keysISRC[track.ISRC] == nil {
+				c.keysISRC[track.ISRC] = make(map[string]bool)
+			}
+			c.keysISRC[track.ISRC][key] = true
+		}
+	}
+}
+
+// Dates returns the loaded snapshot dates in ascending order
+func (c *Chart) Dates() []string {
+	return c.dates
+}
+
+// Snapshot returns the tracks of the snapshot of date
+func (c *Chart) Snapshot(date string) ([]fs.Track, bool) {
+	tracks, ok := c.snapshots[date]
+	return tracks, ok
+}
+
+// Lookup returns the history of track on this chart
+func (c *Chart) Lookup(track fs.Track) (History, bool) {
+	return c.history(c.ranks(track))
+}
+
+// LookupISRC returns the history of the track with isrc on this chart
+func (c *Chart) LookupISRC(isrc string) (History, bool) {
+	return c.history(c.ranksOf(c.keysISRC[isrc]))
+}
+
+// ranksOf merges the appearances of keys into the best rank per date
+func (c *Chart) ranksOf(keys map[string]bool) map[string]int {
+	ranks := make(map[string]int)
+	for key := range keys {
+		for date, rank := range c.byKey[key] {
+			if prev, ok := ranks[date]; !ok || rank < prev {
+				ranks[date] = rank
+			}
+		}
+	}
+	return ranks
+}
+
+// history builds a History from the ranks of a track per date
+func (c *Chart) history(ranks map[string]int) (History, bool) {
+	if len(ranks) == 0 {
+		return History{}, false
+	}
+
+	h := History{Source: c.Source}
+	for _, date := range c.dates {
+		if rank, ok := ranks[date]; ok {
+			h.Appearances = append(h.Appearances, Appearance{Date: date, Rank: rank})
+		}
+	}
+
+	last := h.Appearances[len(h.Appearances)-1]
+	h.FirstSeen = h.Appearances[0].Date
+	h.LastSeen = last.Date
+	h.Rank = last.Rank
+	h.OnChart = last.Date == c.dates[len(c.dates)-1]
+
+	for _, a := range h.Appearances {
+		if h.PeakRank == 0 || a.Rank < h.PeakRank {
+			h.PeakRank = a.Rank
+		}
+	}
+
+	// Walk back from LastSeen over consecutive snapshots containing the track
+	i := sort.SearchStrings(c.dates, last.Date)
+	if i > 0 {
+		h.PreviousRank = ranks[c.dates[i-1]]
+	}
+	h.StreakSince = last.Date
+	for ; i >= 0; i-- {
+		if _, ok := ranks[c.dates[i]]; !ok {
+			break
+		}
+		h.Streak++
+		h.StreakSince = c.dates[i]
+	}
+
+	days := daysBetween(h.StreakSince, h.LastSeen)
+	h.DaysOnChart = days + 1
+	h.WeeksOnChart = days/7 + 1
+	return h, true
+}
+
+func daysBetween(from, to string) int {
+	a, errA := time.Parse("2006-01-02", from)
+	b, errB := time.Parse("2006-01-02", to)
+	if errA != nil || errB != nil {
+		return 0
+	}
+	return int(b.Sub(a).Hours() / 24)
+}
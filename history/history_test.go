@@ -0,0 +1,85 @@
+package history
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	fs "melodex/firestore"
+	"melodex/store"
+)
+
+func TestLookup_Streaks(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+	saturn := func(rank int, isrc string) fs.Track {
+		return fs.Track{Rank: rank, Artist: "SZA", Title: "Saturn", ISRC: isrc}
+	}
+	other := fs.Track{Rank: 1, Artist: "Future", Title: "Type Shit"}
+
+	// Weekly chart: on, off, then three weeks on. The ISRC is missing once.
+	st.SaveSnapshot(ctx, "billboard", "2024-01-02", []fs.Track{saturn(9, "USRC12400001")})
+	st.SaveSnapshot(ctx, "billboard", "2024-01-09", []fs.Track{other})
+	st.SaveSnapshot(ctx, "billboard", "2024-01-16", []fs.Track{saturn(5, "USRC12400001")})
+	st.SaveSnapshot(ctx, "billboard", "2024-01-23", []fs.Track{saturn(2, "")})
+	st.SaveSnapshot(ctx, "billboard", "2024-01-30", []fs.Track{other, saturn(3, "USRC12400001")})
+
+	chart, err := Load(ctx, st, "billboard", "", "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	h, ok := chart.LookupISRC("USRC12400001")
+	if !ok {
+		t.Fatal("Expected history for the ISRC")
+	}
+	want := History{
+		Source: "billboard", FirstSeen: "2024-01-02", LastSeen: "2024-01-30", OnChart: true,
+		Streak: 3, StreakSince: "2024-01-16", DaysOnChart: 15, WeeksOnChart: 3,
+		Rank: 3, PeakRank: 2, PreviousRank: 2,
+	}
+	if len(h.Appearances) != 4 {
+		t.Errorf("Expected 4 appearances, got %+v", h.Appearances)
+	}
+	h.Appearances = nil
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("Unexpected history\ngot:  %+v\nwant: %+v", h, want)
+	}
+
+	// Loading up to a date ignores later snapshots
+	chart, _ = Load(ctx, st, "billboard", "", "2024-01-16")
+	h, _ = chart.Lookup(saturn(5, ""))
+	if h.LastSeen != "2024-01-16" || h.Streak != 1 || h.PreviousRank != 0 || h.WeeksOnChart != 1 {
+		t.Errorf("Expected a debut on 2024-01-16, got %+v", h)
+	}
+
+	// Loading from a date ignores earlier snapshots, as after TTL cleanup
+	chart, _ = Load(ctx, st, "billboard", From("2024-01-30", 7*24*time.Hour), "")
+	h, _ = chart.LookupISRC("USRC12400001")
+	if h.FirstSeen != "2024-01-23" || h.Streak != 2 || h.PeakRank != 2 || h.WeeksOnChart != 2 {
+		t.Errorf("Expected history from 2024-01-23, got %+v", h)
+	}
+
+	if _, ok := chart.LookupISRC("UNKNOWN"); ok {
+		t.Error("Expected no history for an unknown ISRC")
+	}
+}
+
+func TestLookup_DailyChart(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewLocal(t.TempDir())
+	for _, date := range []string{"2024-02-01", "2024-02-02", "2024-02-03"} {
+		st.SaveSnapshot(ctx, "hnhh", date, []fs.Track{{Rank: 4, Artist: "Future", Title: "Type Shit"}})
+	}
+	st.SaveSnapshot(ctx, "hnhh", "2024-02-04", nil)
+
+	chart, err := Load(ctx, st, "hnhh", "", "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	h, ok := chart.Lookup(fs.Track{Artist: "future", Title: "Type-Shit"})
+	if !ok || h.Streak != 3 || h.DaysOnChart != 3 || h.WeeksOnChart != 1 || h.OnChart {
+		t.Errorf("Expected a three day run that has ended, got %+v", h)
+	}
+}
@@ -5,42 +5,88 @@ import (
 	"log"
 	"net/http"
 
-	"cloud.google.com/go/firestore"
-
 	"github.com/gorilla/mux"
 	"go.uber.org/fx"
 
 	cfg "melodex/config"
-	fs "melodex/firestore"
+	"melodex/enrichment"
 	h "melodex/handlers"
 	mb "melodex/musicbrainz"
+	"melodex/scheduler"
+	"melodex/scrapers"
 	spot "melodex/spotify"
+	"melodex/store"
 )
 
 func main() {
 	fx.New(
 		fx.Provide(
 			NewRouter,
-			fs.Options,
+			h.NewScrapeHandler,
+			func(sh *h.ScrapeHandler) scheduler.Runner { return sh },
+			scheduler.Options,
+			store.Options,
 			cfg.Options,
 			spot.Options,
 			mb.Options,
+			scrapers.Options,
+			enrichment.Options,
 		),
 		fx.Invoke(StartServer),
 	).Run()
 }
 
 func NewRouter(
-	db *firestore.Client,
+	lifecycle fx.Lifecycle,
+	st store.Store,
 	sp *spot.SpotifyClient,
-	mb *mb.MusicbrainzClient,
+	sources *scrapers.Registry,
+	scrapeHandler *h.ScrapeHandler,
+	sched *scheduler.Scheduler,
+	config cfg.Config,
 ) *mux.Router {
 	r := mux.NewRouter()
 
-	scrapeHandler := h.NewScrapeHandler(db, sp, mb)
 	r.HandleFunc("/scrape", scrapeHandler.Handle).Methods("POST")
 
-	whosampledHandler := h.NewWhoSampledHandler(db, sp)
+	// Per-source routes, e.g. POST /scrape/billboard-hot-100
+	for _, src := range sources.All() {
+		r.HandleFunc("/scrape/"+src.Target(), scrapeHandler.HandleTarget(src)).Methods("POST")
+	}
+
+	// Scrape job status
+	r.HandleFunc("/jobs", scrapeHandler.HandleJobs).Methods("GET")
+	r.HandleFunc("/jobs/{id}", scrapeHandler.HandleJob).Methods("GET")
+
+	// Per-source run history and health
+	r.HandleFunc("/runs", scrapeHandler.HandleRuns).Methods("GET")
+	r.HandleFunc("/health", scrapeHandler.HandleHealth).Methods("GET")
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if err := scrapeHandler.RecoverJobs(ctx); err != nil {
+				log.Printf("Error recovering scrape jobs: %v", err)
+			}
+			return nil
+		},
+	})
+
+	// Per-source cron schedule
+	scheduleHandler := h.NewScheduleHandler(sched)
+	r.HandleFunc("/schedule", scheduleHandler.Handle).Methods("GET")
+
+	// Stored daily snapshots and the ranked feed built from them
+	tracksHandler := h.NewTracksHandler(st, sources, config)
+	r.HandleFunc("/tracks", tracksHandler.HandleTracks).Methods("GET")
+	r.HandleFunc("/tracks/{id}", tracksHandler.HandleTrack).Methods("GET")
+	r.HandleFunc("/artists/{id}", tracksHandler.HandleArtist).Methods("GET")
+	r.HandleFunc("/tracks/{isrc}/history", tracksHandler.HandleHistory).Methods("GET")
+	r.HandleFunc("/sources/{source}/dates", tracksHandler.HandleDates).Methods("GET")
+	r.HandleFunc("/sources/{source}/diff", tracksHandler.HandleDiff).Methods("GET")
+	r.HandleFunc("/discover", tracksHandler.HandleDiscover).Methods("GET")
+	r.HandleFunc("/discover/rebuild", tracksHandler.HandleRebuildDiscovery).Methods("POST")
+	r.HandleFunc("/scoring/profiles", tracksHandler.HandleProfiles).Methods("GET")
+
+	whosampledHandler := h.NewWhoSampledHandler(st, sp)
 	r.HandleFunc("/whosampled", whosampledHandler.Handle).Methods("POST")
 
 	// Podcast routes
@@ -53,7 +99,7 @@ func NewRouter(
 	return r
 }
 
-func StartServer(lifecycle fx.Lifecycle, router *mux.Router) {
+func StartServer(lifecycle fx.Lifecycle, router *mux.Router, scrapeHandler *h.ScrapeHandler) {
 	server := &http.Server{
 		Addr:    ":8080",
 		Handler: router,
@@ -70,6 +116,11 @@ func StartServer(lifecycle fx.Lifecycle, router *mux.Router) {
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
+			// Stop running scrapes first, so waiting requests can finish with
+			// their interrupted results before the server shuts down
+			if err := scrapeHandler.Shutdown(ctx); err != nil {
+				log.Printf("Error stopping scrape jobs: %v", err)
+			}
 			return server.Shutdown(ctx)
 		},
 	})
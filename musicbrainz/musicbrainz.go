@@ -1,35 +1,166 @@
 package musicbrainz
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/mager/musicbrainz-go/musicbrainz"
+
+	"melodex/store"
 )
 
+const (
+	baseURL   = "https://musicbrainz.org/ws/2"
+	userAgent = "beatbrain/melodex/1.0.0 ( https://github.com/mager/melodex )"
+
+	// maxAttempts bounds the tries of a request MusicBrainz asks to slow down
+	maxAttempts = 3
+
+	// rateLimitName is the document of the shared MusicBrainz budget
+	rateLimitName = "musicbrainz"
+)
+
+// StatusError is returned when MusicBrainz answers with an unexpected status
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // From the Retry-After header, if any
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("musicbrainz returned status %d", e.StatusCode)
+}
+
+// slowDown reports whether MusicBrainz rejected the request for its rate
+func (e *StatusError) slowDown() bool {
+	return e.StatusCode == http.StatusServiceUnavailable || e.StatusCode == http.StatusTooManyRequests
+}
+
+// MusicbrainzClient is the only way to MusicBrainz: every request waits
+// for the rate limiter, and slow-down responses back the limiter off.
+// Responses use the types of github.com/mager/musicbrainz-go.
 type MusicbrainzClient struct {
-	Client      *musicbrainz.MusicbrainzClient
+	http        *http.Client
+	baseURL     string
 	rateLimiter *RateLimiter
 }
 
-func ProvideMusicbrainz() *MusicbrainzClient {
-	var c MusicbrainzClient
-	c.Client = musicbrainz.NewMusicbrainzClient().
-		WithUserAgent("beatbrain/melodex", "1.0.0", "https://github.com/mager/melodex")
+// NewClient creates a client for the MusicBrainz API at base, limited by rateLimiter
+func NewClient(base string, rateLimiter *RateLimiter) *MusicbrainzClient {
+	return &MusicbrainzClient{
+		http:        &http.Client{Timeout: 10 * time.Second},
+		baseURL:     base,
+		rateLimiter: rateLimiter,
+	}
+}
+
+// ProvideMusicbrainz provides a client whose rate limit is shared through
+// the store by every instance
+func ProvideMusicbrainz(st store.Store) *MusicbrainzClient {
 	// MusicBrainz requires at least 3 seconds between requests
-	c.rateLimiter = NewRateLimiter(3 * time.Second)
-	return &c
+	return NewClient(baseURL, NewSharedRateLimiter(st, rateLimitName, 3*time.Second, 1))
+}
+
+// Metrics returns the metrics of the client's rate limiter
+func (c *MusicbrainzClient) Metrics() Metrics {
+	return c.rateLimiter.Metrics()
 }
 
 // SearchRecordingsByISRC searches for recordings by ISRC with rate limiting
-func (c *MusicbrainzClient) SearchRecordingsByISRC(req musicbrainz.SearchRecordingsByISRCRequest) (musicbrainz.SearchRecordingsByISRCResponse, error) {
-	c.rateLimiter.Wait()
-	return c.Client.SearchRecordingsByISRC(req)
+func (c *MusicbrainzClient) SearchRecordingsByISRC(ctx context.Context, req musicbrainz.SearchRecordingsByISRCRequest) (musicbrainz.SearchRecordingsByISRCResponse, error) {
+	var resp musicbrainz.SearchRecordingsByISRCResponse
+	err := c.searchRecordings(ctx, "isrc:"+req.ISRC, 0, &resp)
+	return resp, err
 }
 
 // SearchRecordingsByArtistAndTrack searches for recordings by artist and track with rate limiting
-func (c *MusicbrainzClient) SearchRecordingsByArtistAndTrack(req musicbrainz.SearchRecordingsByArtistAndTrackRequest) (musicbrainz.SearchRecordingsByArtistAndTrackResponse, error) {
-	c.rateLimiter.Wait()
-	return c.Client.SearchRecordingsByArtistAndTrack(req)
+func (c *MusicbrainzClient) SearchRecordingsByArtistAndTrack(ctx context.Context, req musicbrainz.SearchRecordingsByArtistAndTrackRequest) (musicbrainz.SearchRecordingsByArtistAndTrackResponse, error) {
+	var resp musicbrainz.SearchRecordingsByArtistAndTrackResponse
+	query := fmt.Sprintf("artist:%q AND recording:%q", req.Artist, req.Track)
+	err := c.searchRecordings(ctx, query, 25, &resp)
+	return resp, err
+}
+
+// searchRecordings runs a recording search into v, retrying when
+// MusicBrainz asks to slow down
+func (c *MusicbrainzClient) searchRecordings(ctx context.Context, query string, limit int, v interface{}) error {
+	q := url.Values{}
+	q.Set("fmt", "json")
+	q.Set("query", query)
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	u := c.baseURL + "/recording?" + q.Encode()
+
+	for attempt := 1; ; attempt++ {
+		if err := c.rateLimiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		err := c.get(ctx, u, v)
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || !statusErr.slowDown() {
+			if err == nil {
+				c.rateLimiter.Success()
+			}
+			return err
+		}
+
+		c.rateLimiter.Backoff(statusErr.RetryAfter)
+		if attempt == maxAttempts {
+			return err
+		}
+		log.Printf("MusicBrainz asked to slow down (%d), retrying %q", statusErr.StatusCode, query)
+	}
+}
+
+// get fetches u and decodes its JSON body into v
+func (c *MusicbrainzClient) get(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
 }
 
 var Options = ProvideMusicbrainz
@@ -0,0 +1,88 @@
+package musicbrainz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mager/musicbrainz-go/musicbrainz"
+)
+
+func TestSearchRecordingsByISRC_RetriesSlowDowns(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.URL.Query().Get("query"); got != "isrc:USRC12400001" {
+			t.Errorf("Unexpected query %q", got)
+		}
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("Unexpected User-Agent %q", r.Header.Get("User-Agent"))
+		}
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"count": 1, "recordings": [{"id": "saturn-mbid", "title": "Saturn"}]}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, NewRateLimiter(time.Millisecond, 1))
+	resp, err := c.SearchRecordingsByISRC(context.Background(), musicbrainz.SearchRecordingsByISRCRequest{ISRC: "USRC12400001"})
+	if err != nil {
+		t.Fatalf("SearchRecordingsByISRC: %v", err)
+	}
+	if len(resp.Recordings) != 1 || resp.Recordings[0].ID != "saturn-mbid" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if m := c.Metrics(); requests != 2 || m.Requests != 2 || m.SlowDowns != 1 {
+		t.Errorf("Expected one retry after the slow-down, got %d requests and %+v", requests, m)
+	}
+}
+
+func TestSearchRecordings_Errors(t *testing.T) {
+	status := http.StatusInternalServerError
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	c := NewClient(server.URL, NewRateLimiter(time.Millisecond, 1))
+	req := musicbrainz.SearchRecordingsByArtistAndTrackRequest{Artist: "SZA", Track: "Saturn"}
+
+	var statusErr *StatusError
+	if _, err := c.SearchRecordingsByArtistAndTrack(context.Background(), req); !errors.As(err, &statusErr) || statusErr.StatusCode != status || requests != 1 {
+		t.Errorf("Expected a single failed request, got %v after %d requests", err, requests)
+	}
+
+	status, requests = http.StatusServiceUnavailable, 0
+	if _, err := c.SearchRecordingsByArtistAndTrack(context.Background(), req); !errors.As(err, &statusErr) || requests != maxAttempts {
+		t.Errorf("Expected %d attempts while overloaded, got %v after %d requests", maxAttempts, err, requests)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.SearchRecordingsByArtistAndTrack(ctx, req); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled context's error, got %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 2, 4, 16, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"Sun, 04 Feb 2024 16:00:30 GMT": 30 * time.Second,
+		"Sun, 04 Feb 2024 15:00:00 GMT": 0,
+		"soon":                          0,
+	}
+	for value, want := range tests {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -1,38 +1,320 @@
 package musicbrainz
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
 	"sync"
 	"time"
+
+	fs "melodex/firestore"
 )
 
-// RateLimiter implements a simple token bucket rate limiter
+// maxPenaltyFactor caps the adaptive penalty at this many intervals
+const maxPenaltyFactor = 20
+
+// stateTimeout bounds updates of the shared state outside a request's context
+const stateTimeout = 5 * time.Second
+
+// SharedState holds the state of rate limits shared between instances.
+// store.Store implements it with Firestore transactions.
+type SharedState interface {
+	// UpdateRateLimit atomically applies fn to the state of a rate limit,
+	// saving it if fn returns true. fn may run more than once.
+	UpdateRateLimit(ctx context.Context, name string, fn func(state *fs.RateLimit) bool) error
+}
+
+// memoryState is the SharedState of a limiter used by a single process
+type memoryState struct {
+	mu    sync.Mutex
+	state fs.RateLimit
+}
+
+func (m *memoryState) UpdateRateLimit(ctx context.Context, name string, fn func(state *fs.RateLimit) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := m.state
+	if fn(&state) {
+		m.state = state
+	}
+	return nil
+}
+
+// RateLimiter is a token bucket holding up to burst tokens, refilled at one
+// token per interval. Waiters reserve their slot and sleep without holding
+// a lock, so a cancelled waiter never delays the others. The bucket lives in
+// a SharedState, so every instance sharing it shares one budget.
+//
+// When the server asks to slow down, Backoff blocks every request until its
+// Retry-After has passed and adds a penalty to the interval, which doubles
+// with every further slow-down and halves with every success.
 type RateLimiter struct {
-	mu       sync.Mutex
+	name     string
 	interval time.Duration
-	last     time.Time
+	burst    int
+	state    SharedState
+
+	mu           sync.Mutex
+	metrics      Metrics       // Of this instance only
+	penalty      time.Duration // As last seen in the shared state
+	blockedUntil time.Time     // As last seen in the shared state
+}
+
+// Metrics describes the requests a RateLimiter let through and how long
+// they queued for.
+type Metrics struct {
+	Requests     int64         // Waits that ended in a request
+	Cancelled    int64         // Waits whose context was done first
+	Waiting      int           // Requests queued right now
+	TotalWait    time.Duration // Queue time of every request
+	MaxWait      time.Duration // Longest queue time
+	LastWait     time.Duration // Queue time of the latest request
+	SlowDowns    int64         // Times the server asked to slow down
+	Penalty      time.Duration // Current extra interval
+	BlockedUntil time.Time     // Set while honoring a Retry-After
+}
+
+// AverageWait is the mean queue time of a request
+func (m Metrics) AverageWait() time.Duration {
+	if m.Requests == 0 {
+		return 0
+	}
+	return m.TotalWait / time.Duration(m.Requests)
+}
+
+// MarshalJSON reports durations in milliseconds, like the run records
+func (m Metrics) MarshalJSON() ([]byte, error) {
+	var blockedUntil *time.Time
+	if !m.BlockedUntil.IsZero() {
+		blockedUntil = &m.BlockedUntil
+	}
+	return json.Marshal(struct {
+		Requests      int64      `json:"requests"`
+		Cancelled     int64      `json:"cancelled"`
+		Waiting       int        `json:"waiting"`
+		AverageWaitMs int64      `json:"averageWaitMs"`
+		MaxWaitMs     int64      `json:"maxWaitMs"`
+		LastWaitMs    int64      `json:"lastWaitMs"`
+		SlowDowns     int64      `json:"slowDowns"`
+		PenaltyMs     int64      `json:"penaltyMs"`
+		BlockedUntil  *time.Time `json:"blockedUntil,omitempty"`
+	}{
+		m.Requests, m.Cancelled, m.Waiting,
+		m.AverageWait().Milliseconds(), m.MaxWait.Milliseconds(), m.LastWait.Milliseconds(),
+		m.SlowDowns, m.Penalty.Milliseconds(), blockedUntil,
+	})
 }
 
-// NewRateLimiter creates a new rate limiter with the specified interval between requests
-func NewRateLimiter(interval time.Duration) *RateLimiter {
+// NewRateLimiter creates a rate limiter for this process, allowing burst
+// requests at once and one more every interval
+func NewRateLimiter(interval time.Duration, burst int) *RateLimiter {
+	return NewSharedRateLimiter(&memoryState{}, "", interval, burst)
+}
+
+// NewSharedRateLimiter creates a rate limiter like NewRateLimiter, whose
+// budget is shared through the rate limit name in state
+func NewSharedRateLimiter(state SharedState, name string, interval time.Duration, burst int) *RateLimiter {
 	return &RateLimiter{
+		name:     name,
 		interval: interval,
-		last:     time.Time{},
+		burst:    max(burst, 1),
+		state:    state,
 	}
 }
 
-// Wait blocks until the next request can be made according to the rate limit
-func (r *RateLimiter) Wait() {
+// Wait blocks until the next request can be made according to the rate limit,
+// or returns ctx's error if ctx is done first
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var slot, reserved, previous time.Time
+	err := r.update(ctx, func(state *fs.RateLimit) bool {
+		slot, reserved, previous = r.reserve(state, time.Now())
+		return true
+	})
+	if err != nil {
+		r.mu.Lock()
+		r.metrics.Cancelled++
+		r.mu.Unlock()
+		return r.stateError(ctx, err)
+	}
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	r.metrics.Waiting++
+	r.mu.Unlock()
+
+	for {
+		if wait := time.Until(slot); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				r.release(reserved, previous)
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
 
-	now := time.Now()
-	if !r.last.IsZero() {
-		// Calculate how long to wait
-		elapsed := now.Sub(r.last)
-		if elapsed < r.interval {
-			// Sleep for the remaining time
-			time.Sleep(r.interval - elapsed)
+		// A slow-down while queued also holds back reserved slots
+		var blockedUntil time.Time
+		err := r.update(ctx, func(state *fs.RateLimit) bool {
+			blockedUntil = state.BlockedUntil
+			return false
+		})
+		if err != nil {
+			r.release(reserved, previous)
+			return r.stateError(ctx, err)
+		}
+		if blockedUntil.After(time.Now()) {
+			slot = blockedUntil
+			continue
 		}
+
+		waited := time.Since(start)
+		r.mu.Lock()
+		r.metrics.Waiting--
+		r.metrics.Requests++
+		r.metrics.TotalWait += waited
+		r.metrics.LastWait = waited
+		r.metrics.MaxWait = max(r.metrics.MaxWait, waited)
+		r.mu.Unlock()
+		return nil
+	}
+}
+
+// update applies fn to the shared state, remembering what it last held
+func (r *RateLimiter) update(ctx context.Context, fn func(state *fs.RateLimit) bool) error {
+	var seen fs.RateLimit
+	err := r.state.UpdateRateLimit(ctx, r.name, func(state *fs.RateLimit) bool {
+		changed := fn(state)
+		seen = *state
+		return changed
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.penalty = seen.Penalty
+	r.blockedUntil = seen.BlockedUntil
+	r.mu.Unlock()
+	return nil
+}
+
+// stateError is the error of a wait that couldn't reach the shared state
+func (r *RateLimiter) stateError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("reading rate limit %s: %w", r.name, err)
+}
+
+// release gives up a reservation. The slot is handed back unless a later
+// waiter already queued behind it. Firestore stores times in microseconds,
+// so the stored TAT is compared at that precision.
+func (r *RateLimiter) release(reserved, previous time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	r.update(ctx, func(state *fs.RateLimit) bool {
+		if !state.TAT.Truncate(time.Microsecond).Equal(reserved.Truncate(time.Microsecond)) {
+			return false
+		}
+		state.TAT = previous
+		return true
+	})
+
+	r.mu.Lock()
+	r.metrics.Waiting--
+	r.metrics.Cancelled++
+	r.mu.Unlock()
+}
+
+// reserve returns the time the caller may send its request at, and the
+// theoretical arrival times after and before the reservation
+func (r *RateLimiter) reserve(state *fs.RateLimit, now time.Time) (slot, reserved, previous time.Time) {
+	interval := r.interval + state.Penalty
+	tolerance := time.Duration(r.burst-1) * interval
+
+	slot = now
+	if earliest := state.TAT.Add(-tolerance); earliest.After(slot) {
+		slot = earliest
+	}
+	if state.BlockedUntil.After(slot) {
+		slot = state.BlockedUntil
+	}
+
+	previous = state.TAT
+	base := state.TAT
+	if slot.After(base) {
+		base = slot
+	}
+	state.TAT = base.Add(interval)
+	return slot, state.TAT, previous
+}
+
+// Backoff slows the limiter down after the server asked to. No request is
+// let through for retryAfter, or for the penalty if that is longer.
+func (r *RateLimiter) Backoff(retryAfter time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	err := r.update(ctx, func(state *fs.RateLimit) bool {
+		if state.Penalty == 0 {
+			state.Penalty = r.interval
+		} else {
+			state.Penalty = min(2*state.Penalty, maxPenaltyFactor*r.interval)
+		}
+		if until := time.Now().Add(max(retryAfter, state.Penalty)); until.After(state.BlockedUntil) {
+			state.BlockedUntil = until
+		}
+		return true
+	})
+
+	if err != nil {
+		log.Printf("Error backing off rate limit %s: %v", r.name, err)
+	}
+
+	r.mu.Lock()
+	r.metrics.SlowDowns++
+	r.mu.Unlock()
+}
+
+// Success lets the limiter recover from earlier slow-downs
+func (r *RateLimiter) Success() {
+	r.mu.Lock()
+	penalty := r.penalty
+	r.mu.Unlock()
+	if penalty == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	r.update(ctx, func(state *fs.RateLimit) bool {
+		if state.Penalty == 0 {
+			return false
+		}
+		state.Penalty /= 2
+		if state.Penalty < r.interval/4 {
+			state.Penalty = 0
+		}
+		return true
+	})
+}
+
+// Metrics returns a snapshot of this instance's metrics, with the penalty
+// and block last seen in the shared state
+func (r *RateLimiter) Metrics() Metrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.metrics
+	m.Penalty = r.penalty
+	if r.blockedUntil.After(time.Now()) {
+		m.BlockedUntil = r.blockedUntil
 	}
-	r.last = time.Now()
+	return m
 }
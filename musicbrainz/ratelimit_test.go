@@ -0,0 +1,168 @@
+package musicbrainz
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	fs "melodex/firestore"
+	"melodex/store"
+)
+
+func TestRateLimiter_SpacesRequests(t *testing.T) {
+	r := NewRateLimiter(30*time.Millisecond, 1)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := r.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Expected three requests to take two intervals, took %v", elapsed)
+	}
+
+	m := r.Metrics()
+	if m.Requests != 3 || m.Waiting != 0 || m.MaxWait < 20*time.Millisecond || m.AverageWait() <= 0 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+}
+
+func TestRateLimiter_Burst(t *testing.T) {
+	r := NewRateLimiter(time.Hour, 2)
+	for i := 0; i < 2; i++ {
+		if err := r.Wait(context.Background()); err != nil {
+			t.Fatalf("Expected a burst of two, got %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the third request to wait past its deadline, got %v", err)
+	}
+	if m := r.Metrics(); m.Requests != 2 || m.Cancelled != 1 || m.Waiting != 0 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+}
+
+func TestRateLimiter_CancelledWaitFreesItsSlot(t *testing.T) {
+	r := NewRateLimiter(100*time.Millisecond, 1)
+	r.Wait(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a cancelled wait, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r.Wait(ctx)
+
+	start := time.Now()
+	if err := r.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Expected the cancelled slot to be handed back, waited %v", elapsed)
+	}
+}
+
+// microsecondState stores times at Firestore's precision. Another instance
+// blocks requests until block right after the first reservation.
+type microsecondState struct {
+	memoryState
+	updates int
+	block   time.Time
+}
+
+func (m *microsecondState) UpdateRateLimit(ctx context.Context, name string, fn func(state *fs.RateLimit) bool) error {
+	if m.updates++; m.updates == 2 {
+		m.state.BlockedUntil = m.block
+	}
+	return m.memoryState.UpdateRateLimit(ctx, name, func(state *fs.RateLimit) bool {
+		changed := fn(state)
+		state.TAT = state.TAT.Truncate(time.Microsecond)
+		return changed
+	})
+}
+
+func TestSharedRateLimiter_CancelledWaitFreesItsSlot(t *testing.T) {
+	st := &microsecondState{block: time.Now().Add(time.Hour)}
+	r := NewSharedRateLimiter(st, "musicbrainz", time.Hour, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a wait past its deadline, got %v", err)
+	}
+	if !st.state.TAT.IsZero() {
+		t.Errorf("Expected the cancelled slot to be handed back, TAT is %v", st.state.TAT)
+	}
+}
+
+func TestRateLimiter_Backoff(t *testing.T) {
+	r := NewRateLimiter(10*time.Millisecond, 1)
+	r.Wait(context.Background())
+
+	r.Backoff(80 * time.Millisecond)
+	m := r.Metrics()
+	if m.SlowDowns != 1 || m.Penalty != 10*time.Millisecond || m.BlockedUntil.IsZero() {
+		t.Errorf("Unexpected metrics after a slow-down %+v", m)
+	}
+
+	start := time.Now()
+	r.Wait(context.Background())
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("Expected Retry-After to be honored, waited %v", elapsed)
+	}
+
+	r.Backoff(0)
+	if m := r.Metrics(); m.Penalty != 20*time.Millisecond {
+		t.Errorf("Expected the penalty to double, got %v", m.Penalty)
+	}
+	for i := 0; i < 4; i++ {
+		r.Success()
+	}
+	if m := r.Metrics(); m.Penalty != 0 {
+		t.Errorf("Expected successes to clear the penalty, got %v", m.Penalty)
+	}
+}
+
+func TestSharedRateLimiter_SharesOneBudget(t *testing.T) {
+	st := store.NewLocal(t.TempDir())
+	instances := []*RateLimiter{
+		NewSharedRateLimiter(st, "musicbrainz", 30*time.Millisecond, 1),
+		NewSharedRateLimiter(st, "musicbrainz", 30*time.Millisecond, 1),
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, r := range instances {
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.Wait(context.Background())
+			}()
+		}
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected four requests from two instances to take three intervals, took %v", elapsed)
+	}
+
+	// A slow-down seen by one instance holds back the other
+	instances[0].Backoff(80 * time.Millisecond)
+	start = time.Now()
+	instances[1].Wait(context.Background())
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("Expected the other instance to honor Retry-After, waited %v", elapsed)
+	}
+	if m := instances[1].Metrics(); m.Penalty != 30*time.Millisecond || m.Requests != 3 {
+		t.Errorf("Unexpected metrics of the other instance %+v", m)
+	}
+}
@@ -0,0 +1,231 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/fx"
+
+	"melodex/config"
+	fs "melodex/firestore"
+	"melodex/scrapers"
+)
+
+// Off disables the schedule of a source in config.Schedules.
+const Off = "off"
+
+// Runner runs a scrape job for a single source and returns its report.
+type Runner interface {
+	RunScheduled(ctx context.Context, src scrapers.Source) (fs.ScrapeJob, error)
+}
+
+// Entry is the schedule of one source, as shown by GET /schedule.
+type Entry struct {
+	Source  string     `json:"source"`
+	Target  string     `json:"target"`
+	Cadence string     `json:"cadence,omitempty"` // Empty when the source is manual only
+	Next    *time.Time `json:"next,omitempty"`
+	Running bool       `json:"running"`
+	LastRun *Run       `json:"lastRun,omitempty"`
+}
+
+// Run is the outcome of a scheduled run.
+type Run struct {
+	JobID      string    `json:"jobID,omitempty"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// Scheduler runs every source on its own cron cadence. A run that is still
+// going when the next one is due makes the next one skip.
+type Scheduler struct {
+	cron    *cron.Cron
+	runner  Runner
+	jitter  time.Duration
+	enabled bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	entries []*entry
+}
+
+type entry struct {
+	src     scrapers.Source
+	cadence string
+	id      cron.EntryID
+	running bool
+	last    *Run
+}
+
+// New schedules the sources on their cadence, or on the override from
+// schedules (keyed by collection). Runs start after a random delay of up
+// to jitter, so sources sharing a cadence don't hit upstreams at once.
+func New(runner Runner, sources []scrapers.Source, schedules map[string]string, jitter time.Duration) (*Scheduler, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		cron:    cron.New(),
+		runner:  runner,
+		jitter:  jitter,
+		enabled: true,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	for _, src := range sources {
+		cadence := src.Cadence()
+		if override, ok := schedules[src.Collection()]; ok {
+			cadence = override
+		}
+		if cadence == Off {
+			cadence = ""
+		}
+
+		e := &entry{src: src, cadence: cadence}
+		if cadence != "" {
+			id, err := s.cron.AddFunc(cadence, func() { s.run(e) })
+			if err != nil {
+				cancel()
+				return nil, fmt.Errorf("invalid schedule %q for %s: %w", cadence, src.Collection(), err)
+			}
+			e.id = id
+		}
+		s.entries = append(s.entries, e)
+	}
+	return s, nil
+}
+
+// ProvideScheduler creates the scheduler and ties it to the app lifecycle.
+func ProvideScheduler(lifecycle fx.Lifecycle, cfg config.Config, sources *scrapers.Registry, runner Runner) *Scheduler {
+	s, err := New(runner, sources.All(), cfg.Schedules, cfg.ScheduleJitter)
+	if err != nil {
+		log.Fatalf("Error creating scheduler: %v", err)
+	}
+	s.enabled = cfg.Scheduler
+
+	lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			s.Start()
+			return nil
+		},
+		OnStop: s.Stop,
+	})
+	return s
+}
+
+var Options = ProvideScheduler
+
+// Start starts the scheduler unless it was disabled in config.
+func (s *Scheduler) Start() {
+	if !s.enabled {
+		log.Printf("Scheduler disabled")
+		return
+	}
+	s.cron.Start()
+	log.Printf("Scheduler started with %d scheduled sources", len(s.cron.Entries()))
+}
+
+// Stop cancels pending and running scheduled runs and waits for them to
+// return, or until ctx is done.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+	select {
+	case <-s.cron.Stop().Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Entries returns the schedule of every source in registration order.
+func (s *Scheduler) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entry := Entry{
+			Source:  e.src.Collection(),
+			Target:  e.src.Target(),
+			Cadence: e.cadence,
+			Running: e.running,
+			LastRun: e.last,
+		}
+		if e.id != 0 && s.enabled {
+			if next := s.cron.Entry(e.id).Next; !next.IsZero() {
+				entry.Next = &next
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// run starts a scheduled run of e, unless the previous one is still going
+func (s *Scheduler) run(e *entry) {
+	s.mu.Lock()
+	if e.running {
+		s.mu.Unlock()
+		log.Printf("Skipping scheduled %s run: previous run still in progress", e.src.Name())
+		return
+	}
+	e.running = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		e.running = false
+		s.mu.Unlock()
+	}()
+
+	if !s.wait(s.ctx, s.jitterDelay()) {
+		return
+	}
+
+	log.Printf("Starting scheduled %s run", e.src.Name())
+	last := &Run{StartedAt: time.Now()}
+	job, err := s.runner.RunScheduled(s.ctx, e.src)
+	last.FinishedAt = time.Now()
+	last.JobID = job.ID
+	last.Status = job.Status
+	if err != nil {
+		last.Status = fs.StatusFailed
+		last.Error = err.Error()
+		log.Printf("Scheduled %s run failed: %v", e.src.Name(), err)
+	}
+
+	s.mu.Lock()
+	e.last = last
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) jitterDelay() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(s.jitter)))
+}
+
+// wait pauses for d, returning false if ctx is done first
+func (s *Scheduler) wait(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	fs "melodex/firestore"
+	"melodex/scrapers"
+)
+
+type fakeRunner struct {
+	started chan struct{}
+	release chan struct{}
+	runs    int
+}
+
+func (r *fakeRunner) RunScheduled(ctx context.Context, src scrapers.Source) (fs.ScrapeJob, error) {
+	r.runs++
+	r.started <- struct{}{}
+	<-r.release
+	return fs.ScrapeJob{ID: "job-1", Status: fs.StatusSucceeded}, nil
+}
+
+func fakeSource(collection, cadence string) scrapers.Source {
+	return scrapers.NewSource(scrapers.SourceInfo{
+		Name:       collection,
+		Target:     collection,
+		Collection: collection,
+		Cadence:    cadence,
+	}, func(ctx context.Context, deps scrapers.Deps) ([]fs.Song, error) {
+		return nil, nil
+	})
+}
+
+func TestNew_AppliesOverrides(t *testing.T) {
+	sources := []scrapers.Source{
+		fakeSource("weekly", "0 6 * * 2"),
+		fakeSource("hourly", "0 * * * *"),
+		fakeSource("manual", ""),
+	}
+	s, err := New(&fakeRunner{}, sources, map[string]string{
+		"weekly": "*/30 * * * *",
+		"hourly": Off,
+	}, 0)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	s.Start()
+	defer s.Stop(context.Background())
+
+	entries := s.Entries()
+	if entries[0].Cadence != "*/30 * * * *" || entries[0].Next == nil {
+		t.Errorf("Expected the override to be scheduled, got %+v", entries[0])
+	}
+	if entries[1].Cadence != "" || entries[1].Next != nil {
+		t.Errorf("Expected the disabled source to have no schedule, got %+v", entries[1])
+	}
+	if entries[2].Next != nil {
+		t.Errorf("Expected the manual source to have no next run, got %+v", entries[2])
+	}
+}
+
+func TestNew_RejectsInvalidCron(t *testing.T) {
+	_, err := New(&fakeRunner{}, []scrapers.Source{fakeSource("bad", "every tuesday")}, nil, 0)
+	if err == nil {
+		t.Error("Expected an error for an invalid cron expression")
+	}
+}
+
+func TestRun_SkipsOverlappingRuns(t *testing.T) {
+	runner := &fakeRunner{started: make(chan struct{}), release: make(chan struct{})}
+	s, err := New(runner, []scrapers.Source{fakeSource("slow", "* * * * *")}, nil, 0)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	e := s.entries[0]
+
+	done := make(chan struct{})
+	go func() {
+		s.run(e)
+		close(done)
+	}()
+	<-runner.started
+
+	// The second run is skipped while the first is in progress
+	s.run(e)
+	if !s.Entries()[0].Running {
+		t.Error("Expected the entry to be running")
+	}
+
+	close(runner.release)
+	<-done
+
+	last := s.Entries()[0].LastRun
+	if runner.runs != 1 || last == nil || last.JobID != "job-1" || last.Status != fs.StatusSucceeded {
+		t.Errorf("Expected exactly one recorded run, got %d runs, last %+v", runner.runs, last)
+	}
+}
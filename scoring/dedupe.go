@@ -0,0 +1,248 @@
+package scoring
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// possibleDuplicateSimilarity is the title similarity from which tracks by
+// the same artist that weren't merged are reported as possible duplicates
+const possibleDuplicateSimilarity = 0.8
+
+var (
+	// Featuring credits and everything after them
+	featuring = regexp.MustCompile(`\s*[(\[]?\b(feat\.?|ft\.?|featuring)\s.*$`)
+	// Parentheticals like (Remix), (Live) or [Remastered]
+	parenthetical = regexp.MustCompile(`\s*[(\[][^)\]]*[)\]]`)
+	// Version suffixes like " - Remastered 2011" or " - Live at Wembley"
+	versionSuffix = regexp.MustCompile(`\s+-\s+.*\b(remaster(ed)?|live|remix|mix|version|edit|mono|stereo)\b.*$`)
+	ampersand     = regexp.MustCompile(`\s*&\s*`)
+
+	punctuation = strings.NewReplacer("’", "'", "‘", "'", "“", `"`, "”", `"`, "–", "-", "—", "-")
+	separators  = strings.NewReplacer("-", " ", "_", " ")
+)
+
+// normalizeKey returns the text key of a track, "artist - title", for
+// matching tracks without shared identifiers. Only the primary artist
+// counts; featuring credits, version parentheticals, diacritics, hyphens
+// and "&" versus "and" are ignored.
+func normalizeKey(artist, title string) string {
+	artist = fold(artist)
+	artist = featuring.ReplaceAllString(artist, "")
+	artist, _, _ = strings.Cut(artist, ",")
+
+	title = fold(title)
+	title = parenthetical.ReplaceAllString(title, "")
+	title = versionSuffix.ReplaceAllString(title, "")
+	title = featuring.ReplaceAllString(title, "")
+	title = ampersand.ReplaceAllString(title, " and ")
+	title = separators.Replace(title)
+
+	return NormalizeName(artist) + " - " + strings.Join(strings.Fields(title), " ")
+}
+
+// NormalizeName folds a single artist name for matching, ignoring case,
+// diacritics, hyphens and "&" versus "and"
+func NormalizeName(name string) string {
+	name = fold(name)
+	name = ampersand.ReplaceAllString(name, " and ")
+	name = separators.Replace(name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// fold lowercases s and strips diacritics and typographic punctuation
+func fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	if folded, _, err := transform.String(t, s); err == nil {
+		s = folded
+	}
+	return punctuation.Replace(strings.ToLower(s))
+}
+
+// identity is the set of identifiers of a group of tracks, by kind
+type identity map[string]map[string]bool
+
+func (id identity) add(kind, value string) {
+	if value == "" {
+		return
+	}
+	if id[kind] == nil {
+		id[kind] = make(map[string]bool)
+	}
+	id[kind][value] = true
+}
+
+func (id identity) merge(other identity) {
+	for kind, values := range other {
+		for value := range values {
+			id.add(kind, value)
+		}
+	}
+}
+
+// conflicts reports whether both groups have identifiers of a kind, but
+// none in common, making them different recordings
+func (id identity) conflicts(other identity) bool {
+	for kind, values := range id {
+		if len(other[kind]) == 0 {
+			continue
+		}
+		shared := false
+		for value := range values {
+			if other[kind][value] {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			return true
+		}
+	}
+	return false
+}
+
+func identityOf(track ScoredTrack) identity {
+	id := make(identity)
+	id.add("isrc", strings.ToUpper(strings.TrimSpace(track.ISRC)))
+	id.add("mbid", strings.ToLower(strings.TrimSpace(track.MBID)))
+	id.add("spotify", strings.TrimSpace(track.SpotifyID))
+	return id
+}
+
+// groupDuplicates groups the tracks that are the same song: first those
+// sharing an ISRC, MBID or Spotify ID, then those with the same
+// normalizeKey unless their identifiers conflict. Groups keep the order
+// they were first seen in.
+func groupDuplicates(tracks []ScoredTrack) [][]ScoredTrack {
+	// Union-find over track indexes, rooted at the first track of a group
+	parent := make([]int, len(tracks))
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	ids := make(map[int]identity, len(tracks))
+	union := func(a, b int) {
+		a, b = find(a), find(b)
+		if a == b {
+			return
+		}
+		if b < a {
+			a, b = b, a
+		}
+		parent[b] = a
+		ids[a].merge(ids[b])
+		delete(ids, b)
+	}
+
+	byID := make(map[string]int)
+	for i, track := range tracks {
+		ids[i] = identityOf(track)
+		for kind, values := range ids[i] {
+			for value := range values {
+				if j, ok := byID[kind+":"+value]; ok {
+					union(i, j)
+				} else {
+					byID[kind+":"+value] = i
+				}
+			}
+		}
+	}
+
+	byKey := make(map[string][]int)
+	for i, track := range tracks {
+		key := normalizeKey(track.Artist, track.Title)
+		merged := false
+		for _, j := range byKey[key] {
+			a, b := find(i), find(j)
+			if a == b || !ids[a].conflicts(ids[b]) {
+				union(a, b)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			byKey[key] = append(byKey[key], i)
+		}
+	}
+
+	var groups [][]ScoredTrack
+	index := make(map[int]int)
+	for i, track := range tracks {
+		root := find(i)
+		g, ok := index[root]
+		if !ok {
+			g = len(groups)
+			index[root] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], track)
+	}
+	return groups
+}
+
+// possibleDuplicates pairs up groups by the same artist whose titles are
+// similar but weren't merged, by group index
+func possibleDuplicates(groups [][]ScoredTrack) map[int][]int {
+	type entry struct {
+		group int
+		title string
+	}
+	byArtist := make(map[string][]entry)
+	for g, group := range groups {
+		artist, title, _ := strings.Cut(normalizeKey(group[0].Artist, group[0].Title), " - ")
+		byArtist[artist] = append(byArtist[artist], entry{g, title})
+	}
+
+	pairs := make(map[int][]int)
+	for g, group := range groups {
+		artist, title, _ := strings.Cut(normalizeKey(group[0].Artist, group[0].Title), " - ")
+		for _, other := range byArtist[artist] {
+			if other.group == g || other.title == title {
+				continue
+			}
+			if similarity(title, other.title) >= possibleDuplicateSimilarity {
+				pairs[g] = append(pairs[g], other.group)
+			}
+		}
+	}
+	return pairs
+}
+
+// similarity returns 1 minus the edit distance of a and b relative to the
+// longer of the two, from 0 for unrelated strings to 1 for equal ones
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
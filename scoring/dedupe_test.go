@@ -0,0 +1,101 @@
+package scoring
+
+import (
+	"testing"
+)
+
+func TestNormalizeKey_Variants(t *testing.T) {
+	tests := []struct {
+		artist, title, expected string
+	}{
+		{"Drake, Future", "Life Is Good (Remastered)", "drake - life is good"},
+		{"Drake", "Life Is Good [Live]", "drake - life is good"},
+		{"Drake", "Life Is Good - Remastered 2021", "drake - life is good"},
+		{"Drake", "Life Is Good (feat. Future)", "drake - life is good"},
+		{"Beyoncé", "Déjà Vu", "beyonce - deja vu"},
+		{"Simon & Garfunkel", "Mrs. Robinson", "simon and garfunkel - mrs. robinson"},
+		{"Simon and Garfunkel", "Mrs. Robinson", "simon and garfunkel - mrs. robinson"},
+		{"Daft Punk", "Get Lucky", "daft punk - get lucky"},
+		{"Drake", "God’s Plan", "drake - god's plan"},
+		{"Jay-Z", "Empire State Of Mind", "jay z - empire state of mind"},
+	}
+
+	for _, tt := range tests {
+		got := normalizeKey(tt.artist, tt.title)
+		if got != tt.expected {
+			t.Errorf("normalizeKey(%q, %q) = %q, want %q", tt.artist, tt.title, got, tt.expected)
+		}
+	}
+}
+
+func TestRankAndDeduplicate_SharedIdentifiers(t *testing.T) {
+	tracks := []ScoredTrack{
+		{Artist: "Drake feat. Future", Title: "Life Is Good", Source: "billboard", Rank: 1, ISRC: "USUM72000788"},
+		{Artist: "Drake & Future", Title: "Life is Good (Clean)", Source: "hnhh", Rank: 2, ISRC: "usum72000788", SpotifyID: "abc"},
+		{Artist: "Drizzy", Title: "LIG", Source: "spotify_new_releases", Rank: 1, SpotifyID: "abc"},
+		{Artist: "Drake, Future", Title: "Life Is Good (Remastered)", Source: "reddit_fresh", Rank: 4},
+	}
+
+	result := RankAndDeduplicate(tracks)
+	if len(result) != 1 {
+		t.Fatalf("Expected identifiers and normalized keys to merge every track, got %d tracks", len(result))
+	}
+	if result[0].SourceCount != 4 {
+		t.Errorf("Expected SourceCount=4, got %d", result[0].SourceCount)
+	}
+}
+
+func TestRankAndDeduplicate_ConflictingIdentifiers(t *testing.T) {
+	tracks := []ScoredTrack{
+		{Artist: "SZA", Title: "Saturn", Source: "billboard", Rank: 1, ISRC: "USRC12300001"},
+		{Artist: "SZA", Title: "Saturn (Remix)", Source: "hnhh", Rank: 2, ISRC: "USRC12300002"},
+		{Artist: "SZA", Title: "Saturn", Source: "reddit_fresh", Rank: 3},
+	}
+
+	result := RankAndDeduplicate(tracks)
+	if len(result) != 2 {
+		t.Fatalf("Expected different ISRCs to stay apart, got %d tracks", len(result))
+	}
+	for _, track := range result {
+		if len(track.PossibleDuplicates) != 0 {
+			t.Errorf("Expected no possible duplicates for distinct recordings, got %v", track.PossibleDuplicates)
+		}
+	}
+}
+
+func TestRankAndDeduplicate_SameSourceTwice(t *testing.T) {
+	tracks := []ScoredTrack{
+		{Artist: "Drake", Title: "Big Song", Source: "billboard", Rank: 1},
+		{Artist: "Drake ft. Future", Title: "Big Song", Source: "billboard", Rank: 7},
+	}
+
+	result := RankAndDeduplicate(tracks)
+	if len(result) != 1 || result[0].SourceCount != 1 || result[0].Source != "billboard" {
+		t.Errorf("Expected one track from one source, got %+v", result)
+	}
+}
+
+func TestRankAndDeduplicate_PossibleDuplicates(t *testing.T) {
+	tracks := []ScoredTrack{
+		{Artist: "Drake", Title: "God's Plan", Source: "billboard", Rank: 1},
+		{Artist: "Drake", Title: "Gods Plan", Source: "hnhh", Rank: 2},
+		{Artist: "Drake", Title: "Nonstop", Source: "hnhh", Rank: 3},
+	}
+
+	result := RankAndDeduplicate(tracks)
+	if len(result) != 3 {
+		t.Fatalf("Expected similar titles not to be merged, got %d tracks", len(result))
+	}
+	for _, track := range result {
+		switch track.Title {
+		case "God's Plan":
+			if len(track.PossibleDuplicates) != 1 || track.PossibleDuplicates[0] != "Drake - Gods Plan" {
+				t.Errorf("Expected Gods Plan as possible duplicate, got %v", track.PossibleDuplicates)
+			}
+		case "Nonstop":
+			if len(track.PossibleDuplicates) != 0 {
+				t.Errorf("Expected no possible duplicates, got %v", track.PossibleDuplicates)
+			}
+		}
+	}
+}
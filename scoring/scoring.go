@@ -4,6 +4,10 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"melodex/config"
+	fs "melodex/firestore"
+	"melodex/scrapers"
 )
 
 type ScoredTrack struct {
@@ -19,152 +23,202 @@ type ScoredTrack struct {
 	CreatedAt time.Time `json:"createdAt,omitempty"`
 	
 	// Scoring fields
-	Score        float64 `json:"score"`
-	WeeksOnChart int     `json:"weeksOnChart,omitempty"`
-	SourceCount  int     `json:"sourceCount"`
+	Score          float64            `json:"score"`
+	WeeksOnChart   int                `json:"weeksOnChart,omitempty"`
+	SourceCount    int                `json:"sourceCount"`
+	ScoreBreakdown *fs.ScoreBreakdown `json:"scoreBreakdown,omitempty"` // Set by RankAndDeduplicate
+
+	// "Artist - Title" of similar tracks that weren't merged with this one
+	PossibleDuplicates []string `json:"possibleDuplicates,omitempty"`
+}
+
+// FromTrack converts a stored track for scoring. Source defaults to the
+// collection it was loaded from.
+func FromTrack(track fs.Track, collection string) ScoredTrack {
+	source := track.Source
+	if source == "" {
+		source = collection
+	}
+	return ScoredTrack{
+		Artist:    track.Artist,
+		Title:     track.Title,
+		MBID:      track.MBID,
+		ISRC:      track.ISRC,
+		SpotifyID: track.SpotifyID,
+		Thumb:     track.Thumb,
+		Source:    source,
+		Rank:      track.Rank,
+		CreatedAt: track.CreatedAt,
+	}
 }
 
-// ScoreTrack computes a composite discovery score
+// ScoreTrack computes a composite discovery score with the default profile
 // score = (source_weight * normalized_rank) + freshness_bonus + cross_source_bonus
 func ScoreTrack(track ScoredTrack) float64 {
-	sourceWeight := getSourceWeight(track.Source)
-	
+	return ScoreTrackWith(track, config.DefaultScoringProfile(), time.Now())
+}
+
+// ScoreTrackWith scores a track with a profile, with freshness measured at now
+func ScoreTrackWith(track ScoredTrack, profile config.ScoringProfile, now time.Time) float64 {
+	return total(Explain(track, profile, now))
+}
+
+// Explain returns the components of a track's score under a profile, with
+// freshness measured at now. Sources are those listed in track.Source.
+func Explain(track ScoredTrack, profile config.ScoringProfile, now time.Time) fs.ScoreBreakdown {
+	sourceWeight := getSourceWeight(profile, track.Source)
+
 	// Normalize rank (lower rank = higher score)
-	// For ranks 1-100, map to 1.0-0.0
-	normalizedRank := 1.0 - (float64(track.Rank-1) / 100.0)
+	// For ranks 1 to the profile's depth, map to 1.0-0.0
+	normalizedRank := 1.0 - (float64(track.Rank-1) / float64(profile.RankDepth))
 	if normalizedRank < 0 {
 		normalizedRank = 0
 	}
-	
-	// Base score from source weight and rank
-	baseScore := sourceWeight * normalizedRank
-	
-	// Freshness bonus - newer content gets higher score
-	freshnessBonusScore := calculateFreshnessBonus(track.CreatedAt)
-	
+
 	// Cross-source bonus - tracks appearing in multiple sources get bonus
-	crossSourceBonus := float64(track.SourceCount-1) * 0.1
-	if crossSourceBonus > 0.3 {
-		crossSourceBonus = 0.3 // Cap at 30% bonus
+	crossSourceBonus := float64(track.SourceCount-1) * profile.CrossSourceBonus
+	if crossSourceBonus > profile.CrossSourceCap {
+		crossSourceBonus = profile.CrossSourceCap
 	}
-	
-	finalScore := baseScore + freshnessBonusScore + crossSourceBonus
-	return finalScore
-}
 
-// getSourceWeight returns the weight for each source
-func getSourceWeight(source string) float64 {
-	weights := map[string]float64{
-		"spotify_new_releases": 1.0,
-		"reddit_fresh":         0.9,
-		"hnhh":                 0.7,
-		"pitchfork_bnm":        0.6,
-		"billboard":            0.5,
+	return fs.ScoreBreakdown{
+		SourceWeight:     sourceWeight,
+		NormalizedRank:   normalizedRank,
+		BaseScore:        sourceWeight * normalizedRank,
+		FreshnessBonus:   calculateFreshnessBonus(profile, track.CreatedAt, now),
+		CrossSourceBonus: crossSourceBonus,
+		Sources:          strings.Split(track.Source, ","),
+		Profile:          profile.Name,
 	}
-	
-	if weight, exists := weights[source]; exists {
+}
+
+// total sums the components of a breakdown into a score
+func total(b fs.ScoreBreakdown) float64 {
+	return b.BaseScore + b.FreshnessBonus + b.CrossSourceBonus
+}
+
+// getSourceWeight returns the profile's weight for a source collection,
+// falling back to its registered weight
+func getSourceWeight(profile config.ScoringProfile, source string) float64 {
+	if weight, ok := profile.Weights[source]; ok {
 		return weight
 	}
+	if src, exists := scrapers.Default().ByCollection(source); exists {
+		return src.Weight()
+	}
 	
 	// Default weight for unknown sources
-	return 0.3
+	return profile.DefaultWeight
 }
 
 // calculateFreshnessBonus gives bonus points for recently created tracks
-func calculateFreshnessBonus(createdAt time.Time) float64 {
+func calculateFreshnessBonus(profile config.ScoringProfile, createdAt, now time.Time) float64 {
 	if createdAt.IsZero() {
 		return 0
 	}
 	
-	hoursOld := time.Since(createdAt).Hours()
+	hoursOld := now.Sub(createdAt).Hours()
 	
-	// Fresh content (< 24 hours) gets maximum bonus
-	if hoursOld < 24 {
-		return 0.2
+	// Fresh content gets maximum bonus
+	if hoursOld < profile.FreshHours {
+		return profile.FreshnessBonus
 	}
 	
-	// Gradually decrease bonus over a week
-	if hoursOld < 168 { // 7 days
-		return 0.2 * (1.0 - (hoursOld-24)/144) // Linear decay from 0.2 to 0
+	// Gradually decrease bonus until it goes stale
+	if hoursOld < profile.StaleHours {
+		return profile.FreshnessBonus * (1.0 - (hoursOld-profile.FreshHours)/(profile.StaleHours-profile.FreshHours))
 	}
 	
 	return 0
 }
 
-// normalizeArtistTitle creates a normalized version for deduplication
-func normalizeArtistTitle(artist, title string) string {
-	normalizeStr := func(s string) string {
-		s = strings.ToLower(s)
-		s = strings.ReplaceAll(s, " ", "")
-		s = strings.ReplaceAll(s, "-", "")
-		s = strings.ReplaceAll(s, "_", "")
-		s = strings.ReplaceAll(s, "'", "")
-		s = strings.ReplaceAll(s, "\"", "")
-		return s
-	}
-	
-	return normalizeStr(artist) + "|" + normalizeStr(title)
+// TrackKey returns the text key tracks are deduplicated by when they share
+// no identifiers, for matching the same song across sources and days
+func TrackKey(artist, title string) string {
+	return normalizeKey(artist, title)
 }
 
 // RankAndDeduplicate takes tracks from all sources, scores them, 
-// deduplicates by normalized artist+title, and returns sorted by score descending
+// deduplicates by shared identifiers or normalized artist+title, and returns
+// sorted by score descending
 func RankAndDeduplicate(tracks []ScoredTrack) []ScoredTrack {
-	// Group tracks by normalized artist+title for deduplication
-	trackMap := make(map[string][]ScoredTrack)
-	
-	for _, track := range tracks {
-		key := normalizeArtistTitle(track.Artist, track.Title)
-		trackMap[key] = append(trackMap[key], track)
-	}
+	return RankAndDeduplicateWith(tracks, config.DefaultScoringProfile(), time.Now())
+}
+
+// RankAndDeduplicateWith ranks tracks with a profile, with freshness
+// measured at now. The result only depends on its input: groups keep the
+// order they were first seen in, and score ties are broken by artist and
+// title.
+func RankAndDeduplicateWith(tracks []ScoredTrack, profile config.ScoringProfile, now time.Time) []ScoredTrack {
+	// Group tracks by identifiers, then normalized artist+title
+	groups := groupDuplicates(tracks)
+	similar := possibleDuplicates(groups)
 	
 	var deduplicatedTracks []ScoredTrack
 	
 	// Process each group of duplicate tracks
-	for _, trackGroup := range trackMap {
+	for g, trackGroup := range groups {
+		var track ScoredTrack
 		if len(trackGroup) == 1 {
 			// Single track, just calculate its score
-			track := trackGroup[0]
+			track = trackGroup[0]
 			track.SourceCount = 1
-			track.Score = ScoreTrack(track)
-			deduplicatedTracks = append(deduplicatedTracks, track)
+			breakdown := Explain(track, profile, now)
+			track.Score = total(breakdown)
+			track.ScoreBreakdown = &breakdown
 		} else {
 			// Multiple tracks - merge them intelligently
-			bestTrack := mergeDuplicateTracks(trackGroup)
-			deduplicatedTracks = append(deduplicatedTracks, bestTrack)
+			track = mergeDuplicateTracks(trackGroup, profile, now)
+		}
+		
+		for _, other := range similar[g] {
+			track.PossibleDuplicates = append(track.PossibleDuplicates, groups[other][0].Artist+" - "+groups[other][0].Title)
 		}
+		deduplicatedTracks = append(deduplicatedTracks, track)
 	}
 	
 	// Sort by score descending
-	sort.Slice(deduplicatedTracks, func(i, j int) bool {
-		return deduplicatedTracks[i].Score > deduplicatedTracks[j].Score
+	sort.SliceStable(deduplicatedTracks, func(i, j int) bool {
+		a, b := deduplicatedTracks[i], deduplicatedTracks[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Artist != b.Artist {
+			return a.Artist < b.Artist
+		}
+		return a.Title < b.Title
 	})
 	
 	return deduplicatedTracks
 }
 
 // mergeDuplicateTracks takes duplicate tracks and merges them into the best version
-func mergeDuplicateTracks(tracks []ScoredTrack) ScoredTrack {
+func mergeDuplicateTracks(tracks []ScoredTrack, profile config.ScoringProfile, now time.Time) ScoredTrack {
 	// Start with the track from the highest-weight source
 	bestTrack := tracks[0]
-	bestWeight := getSourceWeight(tracks[0].Source)
+	bestWeight := getSourceWeight(profile, tracks[0].Source)
 	
 	for _, track := range tracks[1:] {
-		weight := getSourceWeight(track.Source)
+		weight := getSourceWeight(profile, track.Source)
 		if weight > bestWeight {
 			bestTrack = track
 			bestWeight = weight
 		}
 	}
 	
-	// Set source count for cross-source bonus
-	bestTrack.SourceCount = len(tracks)
-	
-	// Combine sources for reference
-	sources := make([]string, len(tracks))
-	for i, track := range tracks {
-		sources[i] = track.Source
+	// Collect distinct sources for reference, a source may list the same
+	// song more than once
+	var sources []string
+	seen := make(map[string]bool)
+	for _, track := range tracks {
+		if !seen[track.Source] {
+			seen[track.Source] = true
+			sources = append(sources, track.Source)
+		}
 	}
-	bestTrack.Source = strings.Join(sources, ",")
+	
+	// Set source count for cross-source bonus
+	bestTrack.SourceCount = len(sources)
 	
 	// Use the best available metadata
 	for _, track := range tracks {
@@ -182,8 +236,13 @@ func mergeDuplicateTracks(tracks []ScoredTrack) ScoredTrack {
 		}
 	}
 	
-	// Calculate final score
-	bestTrack.Score = ScoreTrack(bestTrack)
+	// Calculate final score with the best source's weight, then combine
+	// sources
+	breakdown := Explain(bestTrack, profile, now)
+	breakdown.Sources = sources
+	bestTrack.Source = strings.Join(sources, ",")
+	bestTrack.Score = total(breakdown)
+	bestTrack.ScoreBreakdown = &breakdown
 	
 	return bestTrack
 }
\ No newline at end of file
@@ -1,7 +1,11 @@
 package scoring
 
 import (
+	"math"
 	"testing"
+	"time"
+
+	"melodex/config"
 )
 
 func TestScoreTrack_HighRankFreshSpotify(t *testing.T) {
@@ -12,9 +16,10 @@ func TestScoreTrack_HighRankFreshSpotify(t *testing.T) {
 		Rank:         1,
 		WeeksOnChart: 0,
 		SourceCount:  1,
+		CreatedAt:    time.Now(),
 	}
 	score := ScoreTrack(track)
-	if score < 1.5 {
+	if score < 1.2 {
 		t.Errorf("Expected high score for #1 fresh Spotify track, got %f", score)
 	}
 }
@@ -48,8 +53,8 @@ func TestScoreTrack_CrossSourceBonus(t *testing.T) {
 	base.SourceCount = 3
 	scoreMulti := ScoreTrack(base)
 
-	if scoreMulti-scoreSingle < 0.49 {
-		t.Errorf("Cross-source bonus should add ~0.5, got diff %f", scoreMulti-scoreSingle)
+	if math.Abs(scoreMulti-scoreSingle-0.2) > 1e-9 {
+		t.Errorf("Cross-source bonus should add 0.2, got diff %f", scoreMulti-scoreSingle)
 	}
 }
 
@@ -107,3 +112,28 @@ func TestNormalizeKey(t *testing.T) {
 		}
 	}
 }
+
+func TestRankAndDeduplicate_ScoreBreakdown(t *testing.T) {
+	tracks := []ScoredTrack{
+		{Artist: "Kendrick Lamar", Title: "Track A", Source: "hnhh", Rank: 3},
+		{Artist: "Kendrick Lamar", Title: "Track A", Source: "spotify_new_releases", Rank: 3},
+	}
+	result := RankAndDeduplicate(tracks)
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 track after dedup, got %d", len(result))
+	}
+
+	b := result[0].ScoreBreakdown
+	if b == nil {
+		t.Fatal("Expected a score breakdown")
+	}
+	if b.SourceWeight != getSourceWeight(config.DefaultScoringProfile(), "spotify_new_releases") {
+		t.Errorf("Expected the weight of the best source, got %f", b.SourceWeight)
+	}
+	if len(b.Sources) != 2 || b.Profile != config.DefaultScoringProfileName {
+		t.Errorf("Expected both sources and the default profile, got %+v", b)
+	}
+	if sum := b.BaseScore + b.FreshnessBonus + b.CrossSourceBonus; sum != result[0].Score {
+		t.Errorf("Expected the breakdown to sum to the score %f, got %f", result[0].Score, sum)
+	}
+}
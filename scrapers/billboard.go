@@ -1,19 +1,55 @@
 package scrapers
 
 import (
+	"context"
 	"log"
 	"melodex/firestore"
-	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gocolly/colly"
 )
 
+func init() {
+	Register(NewSource(SourceInfo{
+		Name:         "Billboard Hot 100",
+		Target:       "billboard-hot-100",
+		Collection:   "billboard",
+		Weight:       0.5,
+		Cadence:      "0 6 * * 2", // The chart is published on Tuesdays
+		Expectations: billboardExpectations,
+		RequireID:    true,
+	}, func(ctx context.Context, deps Deps) ([]firestore.Song, error) {
+		return ScrapeBillboardHot100(ctx)
+	}))
+
+	// testing re-runs the Billboard scrape into a scratch collection on demand
+	Register(NewSource(SourceInfo{
+		Name:         "Testing",
+		Target:       "testing",
+		Collection:   "testing",
+		Expectations: billboardExpectations,
+		RequireID:    true,
+	}, func(ctx context.Context, deps Deps) ([]firestore.Song, error) {
+		return ScrapeBillboardHot100(ctx)
+	}))
+}
+
+const billboardHot100URL = "https://www.billboard.com/charts/hot-100/"
+
+// billboardExpectations: the chart always has exactly 100 ranked rows
+var billboardExpectations = Expectations{
+	MinSongs:         100,
+	MaxSongs:         100,
+	ContiguousRanks:  true,
+	MinPreviousRatio: 0.9,
+}
+
 // ScrapeBillboardHot100 scrapes the Billboard Hot 100 chart.
-func ScrapeBillboardHot100(w http.ResponseWriter) ([]firestore.Song, error) {
-	c := colly.NewCollector()
+func ScrapeBillboardHot100(ctx context.Context) ([]firestore.Song, error) {
+	c := newCollector(ctx)
 	var songs []firestore.Song
+	var scrapingError error
 
 	c.OnHTML("ul.o-chart-results-list-row", func(e *colly.HTMLElement) {
 		rankStr := e.ChildText("li.o-chart-results-list__item span.c-label.a-font-primary-bold-l")
@@ -37,17 +73,22 @@ func ScrapeBillboardHot100(w http.ResponseWriter) ([]firestore.Song, error) {
 
 	c.OnError(func(r *colly.Response, err error) {
 		log.Printf("Request URL: %s \nError: %v", r.Request.URL, err)
-		http.Error(w, "Error scraping Billboard chart", http.StatusInternalServerError)
+		scrapingError = responseError(r, err)
 	})
 
-	err := c.Visit("https://www.billboard.com/charts/hot-100/")
+	err := c.Visit(billboardHot100URL)
+	if scrapingError != nil {
+		return nil, scrapingError
+	}
 	if err != nil {
 		log.Printf("Error visiting Billboard: %v", err)
-		http.Error(w, "Error scraping Billboard chart", http.StatusInternalServerError)
 		return nil, err
 	}
 
+	if len(songs) == 0 {
+		return nil, layoutChanged("billboard", billboardHot100URL)
+	}
 	return songs, nil
 }
 
-type ScrapeBillboardHot100Func func(http.ResponseWriter) ([]firestore.Song, error)
+type ScrapeBillboardHot100Func func(context.Context) ([]firestore.Song, error)
@@ -0,0 +1,31 @@
+package scrapers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gocolly/colly"
+)
+
+// httpTransport carries every scraper request. Tests replace it to replay
+// recorded fixtures.
+var httpTransport http.RoundTripper = http.DefaultTransport
+
+// newCollector creates a colly collector whose requests are bound to ctx,
+// so cancelling ctx aborts a scrape in flight.
+func newCollector(ctx context.Context, options ...func(*colly.Collector)) *colly.Collector {
+	c := colly.NewCollector(options...)
+	c.WithTransport(contextTransport{ctx: ctx, base: httpTransport})
+	return c
+}
+
+// contextTransport attaches a context to every request, since colly v1
+// has no context support of its own.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
@@ -0,0 +1,59 @@
+package scrapers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gocolly/colly"
+)
+
+var (
+	// ErrBlocked means the upstream refused us (403, 429 or similar).
+	ErrBlocked = errors.New("blocked by upstream")
+	// ErrLayoutChanged means the page loaded but nothing could be parsed from it.
+	ErrLayoutChanged = errors.New("upstream layout changed")
+	// ErrUpstreamStatus means the upstream answered with an error status.
+	ErrUpstreamStatus = errors.New("upstream error status")
+)
+
+// StatusError is returned when an upstream answers with a non-2xx status.
+// It matches ErrUpstreamStatus, and ErrBlocked for refusal statuses.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d", e.URL, e.StatusCode)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrUpstreamStatus:
+		return true
+	case ErrBlocked:
+		return isBlockedStatus(e.StatusCode)
+	}
+	return false
+}
+
+func isBlockedStatus(code int) bool {
+	return code == http.StatusUnauthorized ||
+		code == http.StatusForbidden ||
+		code == http.StatusTooManyRequests
+}
+
+// layoutChanged reports that a page parsed to nothing
+func layoutChanged(source, url string) error {
+	return fmt.Errorf("%s: no songs parsed from %s: %w", source, url, ErrLayoutChanged)
+}
+
+// responseError converts a failed colly response into a StatusError when
+// the upstream answered, or passes the transport error through
+func responseError(r *colly.Response, err error) error {
+	if r != nil && r.StatusCode != 0 {
+		return &StatusError{URL: r.Request.URL.String(), StatusCode: r.StatusCode}
+	}
+	return err
+}
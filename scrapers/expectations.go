@@ -0,0 +1,67 @@
+package scrapers
+
+import (
+	"fmt"
+	"strings"
+
+	fs "melodex/firestore"
+)
+
+// Expectations describe what a healthy scrape of a source looks like, so
+// selector drift shows up as a degraded run instead of quietly bad data.
+// Zero values are not checked.
+type Expectations struct {
+	MinSongs int
+	MaxSongs int
+	// ContiguousRanks requires ranks 1..N in order, as on a chart
+	ContiguousRanks bool
+	// MinPreviousRatio is the smallest acceptable size relative to the
+	// previous snapshot, e.g. 0.5 flags a scrape half as big as last time
+	MinPreviousRatio float64
+}
+
+// Validate returns every way songs fall short of the expectations.
+// previous is the size of the previous snapshot, or 0 if there is none.
+// Every song must have a positive rank, a title and an artist.
+func (x Expectations) Validate(songs []fs.Song, previous int) []string {
+	var problems []string
+
+	if x.MinSongs > 0 && len(songs) < x.MinSongs {
+		problems = append(problems, fmt.Sprintf("got %d songs, expected at least %d", len(songs), x.MinSongs))
+	}
+	if x.MaxSongs > 0 && len(songs) > x.MaxSongs {
+		problems = append(problems, fmt.Sprintf("got %d songs, expected at most %d", len(songs), x.MaxSongs))
+	}
+	if x.MinPreviousRatio > 0 && previous > 0 && float64(len(songs)) < x.MinPreviousRatio*float64(previous) {
+		problems = append(problems, fmt.Sprintf("got %d songs, the previous snapshot had %d", len(songs), previous))
+	}
+
+	var badRanks, noTitle, noArtist, outOfOrder int
+	for i, song := range songs {
+		if song.Rank <= 0 {
+			badRanks++
+		} else if x.ContiguousRanks && song.Rank != i+1 {
+			outOfOrder++
+		}
+		if strings.TrimSpace(song.Title) == "" {
+			noTitle++
+		}
+		if strings.TrimSpace(song.Artist) == "" {
+			noArtist++
+		}
+	}
+
+	if badRanks > 0 {
+		problems = append(problems, fmt.Sprintf("%d songs without a rank", badRanks))
+	}
+	if outOfOrder > 0 {
+		problems = append(problems, fmt.Sprintf("%d songs out of rank order", outOfOrder))
+	}
+	if noTitle > 0 {
+		problems = append(problems, fmt.Sprintf("%d songs without a title", noTitle))
+	}
+	if noArtist > 0 {
+		problems = append(problems, fmt.Sprintf("%d songs without an artist", noArtist))
+	}
+	return problems
+}
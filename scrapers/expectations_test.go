@@ -0,0 +1,41 @@
+package scrapers
+
+import (
+	"testing"
+
+	fs "melodex/firestore"
+)
+
+func TestExpectations_Validate(t *testing.T) {
+	chart := Expectations{MinSongs: 3, MaxSongs: 3, ContiguousRanks: true, MinPreviousRatio: 0.5}
+	good := []fs.Song{
+		{Rank: 1, Title: "Saturn", Artist: "SZA"},
+		{Rank: 2, Title: "Nokia", Artist: "Drake"},
+		{Rank: 3, Title: "Luther", Artist: "Kendrick Lamar"},
+	}
+
+	tests := []struct {
+		name     string
+		songs    []fs.Song
+		previous int
+		problems int
+	}{
+		{"healthy", good, 3, 0},
+		{"too few", good[:2], 0, 1},
+		{"shrunk since the previous snapshot", good[:2], 10, 2},
+		{"unparsed rank and artist", []fs.Song{
+			{Rank: 1, Title: "Saturn", Artist: "SZA"},
+			{Rank: 0, Title: "Nokia", Artist: "Drake"},
+			{Rank: 3, Title: "Luther", Artist: " "},
+		}, 0, 2},
+		{"out of order", []fs.Song{good[1], good[0], good[2]}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if problems := chart.Validate(tt.songs, tt.previous); len(problems) != tt.problems {
+				t.Errorf("Expected %d problems, got %q", tt.problems, problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package scrapers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	fs "melodex/firestore"
+)
+
+var (
+	update = flag.Bool("update", false, "rewrite golden files from the parsed output")
+	record = flag.Bool("record", false, "fetch upstream responses and save them as fixtures")
+)
+
+// fixtureHostHeader carries the upstream host of a request replayed locally
+const fixtureHostHeader = "X-Fixture-Host"
+
+// replay routes every scraper request to a local httptest server serving the
+// fixtures in testdata/<name>. With -record, requests go upstream instead and
+// their responses are saved there. The returned client uses the same routing,
+// for API clients such as Spotify's.
+func replay(t *testing.T, name string) *http.Client {
+	t.Helper()
+	dir := filepath.Join("testdata", name)
+
+	var rt http.RoundTripper
+	if *record {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		rt = &recordingTransport{t: t, dir: dir, base: http.DefaultTransport}
+	} else {
+		srv := httptest.NewServer(fixtureHandler(t, dir))
+		t.Cleanup(srv.Close)
+		target, _ := url.Parse(srv.URL)
+		rt = &replayTransport{target: target, base: http.DefaultTransport}
+	}
+
+	previous, previousRender := httpTransport, renderPage
+	httpTransport = rt
+	renderPage = replayRender(t, dir, rt, previousRender)
+	t.Cleanup(func() { httpTransport, renderPage = previous, previousRender })
+	return &http.Client{Transport: rt}
+}
+
+// replayRender serves browser-rendered pages like other requests. With
+// -record, pages are rendered upstream and their body saved as a fixture.
+func replayRender(t *testing.T, dir string, rt http.RoundTripper, render func(context.Context, string) (string, error)) func(context.Context, string) (string, error) {
+	return func(ctx context.Context, pageURL string) (string, error) {
+		u, err := url.Parse(pageURL)
+		if err != nil {
+			return "", err
+		}
+
+		if *record {
+			body, err := render(ctx, pageURL)
+			if err != nil {
+				return "", err
+			}
+			path := filepath.Join(dir, fixtureKey(u.Host, u)+".html")
+			if err := os.WriteFile(path, trimHTML([]byte(body)), 0o644); err != nil {
+				t.Errorf("Writing fixture: %v", err)
+			}
+			return body, nil
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+}
+
+// replayTransport sends requests to the fixture server, keeping the
+// original host in a header
+type replayTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (rt *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(fixtureHostHeader, req.URL.Host)
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	req.Host = rt.target.Host
+	return rt.base.RoundTrip(req)
+}
+
+// fixtureHandler serves the fixture recorded for each request
+func fixtureHandler(t *testing.T, dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := fixtureKey(r.Header.Get(fixtureHostHeader), r.URL)
+		matches, _ := filepath.Glob(filepath.Join(dir, key+".*"))
+		if len(matches) == 0 {
+			t.Errorf("No fixture for %s%s, expected %s/%s.*", r.Header.Get(fixtureHostHeader), r.URL.RequestURI(), dir, key)
+			http.NotFound(w, r)
+			return
+		}
+
+		body, err := os.ReadFile(matches[0])
+		if err != nil {
+			t.Errorf("Reading fixture: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", mime.TypeByExtension(filepath.Ext(matches[0])))
+		w.Write(body)
+	})
+}
+
+// recordingTransport saves upstream responses as fixtures
+type recordingTransport struct {
+	t    *testing.T
+	dir  string
+	base http.RoundTripper
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	ext, saved := ".html", trimHTML(body)
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		ext, saved = ".json", body
+	}
+	path := filepath.Join(rt.dir, fixtureKey(req.URL.Host, req.URL)+ext)
+	if err := os.WriteFile(path, saved, 0o644); err != nil {
+		rt.t.Errorf("Writing fixture: %v", err)
+	}
+	return resp, nil
+}
+
+// unparsedHTML matches the parts of a page no scraper reads
+var unparsedHTML = regexp.MustCompile(`(?is)<script\b.*?</script>|<style\b.*?</style>|<svg\b.*?</svg>|<noscript\b.*?</noscript>|<!--.*?-->`)
+
+// blankLines matches runs of lines left empty by trimHTML
+var blankLines = regexp.MustCompile(`\n[ \t\r\n]*\n`)
+
+// trimHTML strips scripts, styles, inline SVGs and comments from a recorded
+// page, so the real markup the scrapers parse is small enough to check in
+func trimHTML(page []byte) []byte {
+	page = unparsedHTML.ReplaceAll(page, nil)
+	return blankLines.ReplaceAll(page, []byte("\n"))
+}
+
+var unsafeFixtureChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// fixtureKey names the fixture of a request: its host and path, plus a short
+// hash of the query string when there is one
+func fixtureKey(host string, u *url.URL) string {
+	key := unsafeFixtureChars.ReplaceAllString(host+u.Path, "_")
+	key = strings.Trim(key, "_")
+	if u.RawQuery != "" {
+		sum := sha1.Sum([]byte(u.RawQuery))
+		key += "-" + hex.EncodeToString(sum[:4])
+	}
+	return key
+}
+
+// checkGolden compares songs with testdata/<name>/golden.json, or rewrites
+// the golden file with -update
+func checkGolden(t *testing.T, name string, songs []fs.Song) {
+	t.Helper()
+	path := filepath.Join("testdata", name, "golden.json")
+
+	got, err := json.MarshalIndent(songs, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Parsed songs differ from %s (run with -update if the change is expected)\ngot:\n%s", path, got)
+	}
+}
@@ -1,26 +1,40 @@
 package scrapers
 
 import (
+	"context"
 	"fmt"
 	"log"
 	fs "melodex/firestore" // Assuming melodex is your module name
-	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gocolly/colly"
 )
 
+func init() {
+	Register(NewSource(SourceInfo{
+		Name:         "Hot New Hip Hop",
+		Target:       "hot-new-hip-hop",
+		Collection:   "hnhh",
+		Weight:       0.7,
+		Cadence:      "0 7 * * *",
+		// Items that fail to parse are skipped, so allow a few to go missing
+		Expectations: Expectations{MinSongs: 90, MaxSongs: 100, MinPreviousRatio: 0.9},
+		RequireID:    true,
+	}, func(ctx context.Context, deps Deps) ([]fs.Song, error) {
+		return ScrapeHotNewHipHop(ctx)
+	}))
+}
+
+const hnhhTop100URL = "https://www.hotnewhiphop.com/top100"
+
 // ScrapeHotNewHipHop scrapes the HNHH Top 100 page.
-// The http.ResponseWriter (w) is not directly used in this scraping function
-// but is kept if your design requires it for other reasons (e.g. direct error reporting).
-// It's generally better to return errors and let the HTTP handler manage the response.
-func ScrapeHotNewHipHop(w http.ResponseWriter) ([]fs.Track, error) {
-	c := colly.NewCollector(
+func ScrapeHotNewHipHop(ctx context.Context) ([]fs.Song, error) {
+	c := newCollector(ctx,
 	// It's good practice to set a User-Agent
 	// colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0.4844.51 Safari/537.36"),
 	)
-	var songs []fs.Track
+	var songs []fs.Song
 	var scrapingError error
 
 	// OnHTML callback for each song article
@@ -59,10 +73,18 @@ func ScrapeHotNewHipHop(w http.ResponseWriter) ([]fs.Track, error) {
 		artist := strings.Join(artists, ", ") // Join multiple artists with ", "
 
 		if title != "" && artist != "" {
-			songs = append(songs, fs.Track{
-				Rank:   rankInt,
-				Title:  title,
-				Artist: artist,
+			// The first listed artist is the primary one
+			credits := make([]fs.ArtistCredit, len(artists))
+			for i, name := range artists {
+				credits[i] = fs.ArtistCredit{Name: name, Role: fs.RoleFeatured}
+			}
+			credits[0].Role = fs.RolePrimary
+
+			songs = append(songs, fs.Song{
+				Rank:    rankInt,
+				Title:   title,
+				Artist:  artist,
+				Artists: credits,
 			})
 			// For debugging: log.Printf("Scraped: Rank %d, Title '%s', Artist '%s'", rankInt, title, artist)
 		} else {
@@ -76,29 +98,23 @@ func ScrapeHotNewHipHop(w http.ResponseWriter) ([]fs.Track, error) {
 
 	c.OnError(func(r *colly.Response, err error) {
 		log.Printf("Request URL: %s \nStatus: %d \nError: %v", r.Request.URL, r.StatusCode, err)
-		scrapingError = fmt.Errorf("scraping error for %s: %w (status: %d)", r.Request.URL, err, r.StatusCode)
-		// It's better to let the calling HTTP handler send the HTTP error response.
-		// If w is not nil, you could use it, but it complicates error handling flow.
-		// if w != nil {
-		// 	http.Error(w, "Error scraping HNHH", http.StatusInternalServerError)
-		// }
+		scrapingError = fmt.Errorf("scraping error for %s: %w", r.Request.URL, responseError(r, err))
 	})
 
-	err := c.Visit("https://www.hotnewhiphop.com/top100")
-	if err != nil { // Catches errors before any request is made (e.g., invalid URL)
-		log.Printf("Error initiating visit to HNHH: %v", err)
-		return nil, err
-	}
+	err := c.Visit(hnhhTop100URL)
 
 	// If an error occurred in OnError callback
 	if scrapingError != nil {
 		return nil, scrapingError
 	}
+	if err != nil { // Catches errors before any request is made (e.g., invalid URL)
+		log.Printf("Error initiating visit to HNHH: %v", err)
+		return nil, err
+	}
 
-	if len(songs) == 0 && scrapingError == nil {
+	if len(songs) == 0 {
 		log.Println("No songs were scraped. Check selectors and website structure.")
-		// Optionally return an error here if no songs being scraped is considered an error condition.
-		// return nil, fmt.Errorf("no songs scraped from HNHH")
+		return nil, layoutChanged("hnhh", hnhhTop100URL)
 	}
 
 	return songs, nil
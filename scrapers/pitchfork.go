@@ -1,8 +1,8 @@
 package scrapers
 
 import (
+	"context"
 	"log"
-	"net/http"
 	"strings"
 
 	fs "melodex/firestore"
@@ -10,10 +10,26 @@ import (
 	"github.com/gocolly/colly"
 )
 
+func init() {
+	Register(NewSource(SourceInfo{
+		Name:         "Pitchfork Best New Tracks",
+		Target:       "pitchfork-bnm",
+		Collection:   "pitchfork_bnm",
+		Weight:       0.6,
+		Cadence:      "0 8 * * *",
+		Expectations: Expectations{MinSongs: 5, ContiguousRanks: true, MinPreviousRatio: 0.5},
+	}, func(ctx context.Context, deps Deps) ([]fs.Song, error) {
+		return ScrapePitchforkBestNewTracks(ctx)
+	}))
+}
+
+const pitchforkBestNewTracksURL = "https://pitchfork.com/best/tracks/"
+
 // ScrapePitchforkBestNewTracks scrapes Pitchfork's Best New Tracks page.
-func ScrapePitchforkBestNewTracks(w http.ResponseWriter) ([]fs.Song, error) {
-	c := colly.NewCollector()
+func ScrapePitchforkBestNewTracks(ctx context.Context) ([]fs.Song, error) {
+	c := newCollector(ctx)
 	var songs []fs.Song
+	var scrapingError error
 	rank := 1
 
 	// Set user agent to avoid blocking
@@ -100,22 +116,24 @@ func ScrapePitchforkBestNewTracks(w http.ResponseWriter) ([]fs.Song, error) {
 
 	c.OnError(func(r *colly.Response, err error) {
 		log.Printf("Request URL: %s \nError: %v", r.Request.URL, err)
-		if w != nil {
-			http.Error(w, "Error scraping Pitchfork", http.StatusInternalServerError)
-		}
+		scrapingError = responseError(r, err)
 	})
 
-	err := c.Visit("https://pitchfork.com/best/tracks/")
+	err := c.Visit(pitchforkBestNewTracksURL)
+	if scrapingError != nil {
+		return nil, scrapingError
+	}
 	if err != nil {
 		log.Printf("Error visiting Pitchfork: %v", err)
-		if w != nil {
-			http.Error(w, "Error scraping Pitchfork", http.StatusInternalServerError)
-		}
 		return nil, err
 	}
 
+	if len(songs) == 0 {
+		return nil, layoutChanged("pitchfork", pitchforkBestNewTracksURL)
+	}
+
 	log.Printf("Scraped %d tracks from Pitchfork Best New Tracks", len(songs))
 	return songs, nil
 }
 
-type ScrapePitchforkBestNewTracksFunc func(http.ResponseWriter) ([]fs.Song, error)
\ No newline at end of file
+type ScrapePitchforkBestNewTracksFunc func(context.Context) ([]fs.Song, error)
\ No newline at end of file
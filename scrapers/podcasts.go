@@ -161,9 +161,9 @@ var DefaultPodcastCategories = []CategorySearchQueries{
 	{"Skepticism", []string{"skeptic podcast", "critical thinking podcast", "debunking podcast", "science vs podcast"}},
 }
 
-// ScrapePodcastShows searches Spotify for podcast shows by category queries
-func ScrapePodcastShows(client spotify.Client, queries []string, maxPerQuery int) ([]PodcastShow, error) {
-	ctx := context.Background()
+// ScrapePodcastShows searches Spotify for podcast shows by category queries.
+// It stops at the first query after ctx is done.
+func ScrapePodcastShows(ctx context.Context, client spotify.Client, queries []string, maxPerQuery int) ([]PodcastShow, error) {
 	var allShows []PodcastShow
 	seenIDs := make(map[string]bool)
 
@@ -172,6 +172,9 @@ func ScrapePodcastShows(client spotify.Client, queries []string, maxPerQuery int
 
 		// Search for shows
 		results, err := client.Search(ctx, query, spotify.SearchTypeShow)
+		if ctx.Err() != nil {
+			return allShows, ctx.Err()
+		}
 		if err != nil {
 			log.Printf("Error searching for '%s': %v", query, err)
 			continue
@@ -1,7 +1,9 @@
 package scrapers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,30 +13,51 @@ import (
 	fs "melodex/firestore"
 )
 
+func init() {
+	Register(NewSource(SourceInfo{
+		Name:         "Reddit Fresh",
+		Target:       "reddit-fresh",
+		Collection:   "reddit_fresh",
+		Weight:       0.9,
+		Cadence:      "0 18 * * *", // One snapshot a day, once the day's [FRESH] posts are up
+		// The number of [FRESH] posts swings a lot, so only require some
+		Expectations: Expectations{MinSongs: 1},
+	}, func(ctx context.Context, deps Deps) ([]fs.Song, error) {
+		return ScrapeRedditFresh(ctx)
+	}))
+}
+
 // ScrapeRedditFresh scrapes Reddit's public JSON API for r/listentothis and r/hiphopheads,
 // filtering for posts with "[FRESH]" in the title.
-func ScrapeRedditFresh(w http.ResponseWriter) ([]fs.Song, error) {
+// It only fails when every subreddit fails.
+func ScrapeRedditFresh(ctx context.Context) ([]fs.Song, error) {
 	var allSongs []fs.Song
+	var errs []error
 	subreddits := []string{"listentothis", "hiphopheads"}
 
 	for _, subreddit := range subreddits {
-		songs, err := scrapeSubreddit(subreddit, w)
+		songs, err := scrapeSubreddit(ctx, subreddit)
 		if err != nil {
 			log.Printf("Error scraping r/%s: %v", subreddit, err)
+			errs = append(errs, err)
 			continue
 		}
 		allSongs = append(allSongs, songs...)
 	}
 
+	if len(errs) == len(subreddits) {
+		return nil, errors.Join(errs...)
+	}
+
 	log.Printf("Scraped %d FRESH tracks from Reddit", len(allSongs))
 	return allSongs, nil
 }
 
-func scrapeSubreddit(subreddit string, w http.ResponseWriter) ([]fs.Song, error) {
+func scrapeSubreddit(ctx context.Context, subreddit string) ([]fs.Song, error) {
 	url := fmt.Sprintf("https://www.reddit.com/r/%s/search.json?q=[FRESH]&restrict_sr=1&sort=hot&limit=50", subreddit)
 	
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	client := &http.Client{Transport: httpTransport}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -49,21 +72,15 @@ func scrapeSubreddit(subreddit string, w http.ResponseWriter) ([]fs.Song, error)
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		err := fmt.Errorf("Reddit API returned status %d", resp.StatusCode)
+		err := &StatusError{URL: url, StatusCode: resp.StatusCode}
 		log.Printf("Error: %v", err)
-		if w != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
 		return nil, err
 	}
 
 	var redditResp RedditResponse
 	if err := json.NewDecoder(resp.Body).Decode(&redditResp); err != nil {
 		log.Printf("Error decoding Reddit JSON: %v", err)
-		if w != nil {
-			http.Error(w, "Error decoding Reddit response", http.StatusInternalServerError)
-		}
-		return nil, err
+		return nil, fmt.Errorf("decoding r/%s response: %w: %w", subreddit, ErrLayoutChanged, err)
 	}
 
 	var songs []fs.Song
@@ -138,4 +155,4 @@ type RedditResponse struct {
 	} `json:"data"`
 }
 
-type ScrapeRedditFreshFunc func(http.ResponseWriter) ([]fs.Song, error)
\ No newline at end of file
+type ScrapeRedditFreshFunc func(context.Context) ([]fs.Song, error)
\ No newline at end of file
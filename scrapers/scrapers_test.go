@@ -0,0 +1,102 @@
+package scrapers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+
+	spot "melodex/spotify"
+)
+
+func TestScrapeBillboardHot100(t *testing.T) {
+	replay(t, "billboard")
+	songs, err := ScrapeBillboardHot100(context.Background())
+	if err != nil {
+		t.Fatalf("ScrapeBillboardHot100: %v", err)
+	}
+	checkGolden(t, "billboard", songs)
+}
+
+func TestScrapeHotNewHipHop(t *testing.T) {
+	replay(t, "hnhh")
+	songs, err := ScrapeHotNewHipHop(context.Background())
+	if err != nil {
+		t.Fatalf("ScrapeHotNewHipHop: %v", err)
+	}
+	checkGolden(t, "hnhh", songs)
+}
+
+func TestScrapePitchforkBestNewTracks(t *testing.T) {
+	replay(t, "pitchfork")
+	songs, err := ScrapePitchforkBestNewTracks(context.Background())
+	if err != nil {
+		t.Fatalf("ScrapePitchforkBestNewTracks: %v", err)
+	}
+	checkGolden(t, "pitchfork", songs)
+}
+
+func TestScrapeRedditFresh(t *testing.T) {
+	replay(t, "reddit")
+	songs, err := ScrapeRedditFresh(context.Background())
+	if err != nil {
+		t.Fatalf("ScrapeRedditFresh: %v", err)
+	}
+	checkGolden(t, "reddit", songs)
+}
+
+func TestScrapeSpotifyNewReleases(t *testing.T) {
+	client := replay(t, "spotify_new_releases")
+	sp := &spot.SpotifyClient{Client: spotify.New(client)}
+	songs, err := ScrapeSpotifyNewReleases(context.Background(), sp)
+	if err != nil {
+		t.Fatalf("ScrapeSpotifyNewReleases: %v", err)
+	}
+	checkGolden(t, "spotify_new_releases", songs)
+}
+
+func TestScrapeWhoSampled(t *testing.T) {
+	replay(t, "whosampled")
+	songs, err := ScrapeWhoSampled(context.Background(), "Stronger")
+	if err != nil {
+		t.Fatalf("ScrapeWhoSampled: %v", err)
+	}
+	checkGolden(t, "whosampled", songs)
+}
+
+func TestTrimHTML(t *testing.T) {
+	page := "<html><head><script src=\"a.js\"></script>\n<STYLE>ul { }</STYLE></head>\n\n\n" +
+		"<body><!-- ad --><ul class=\"o-chart-results-list-row\"><li><svg><path/></svg>Saturn</li></ul></body></html>"
+	want := "<html><head>\n</head>\n<body><ul class=\"o-chart-results-list-row\"><li>Saturn</li></ul></body></html>"
+	if got := string(trimHTML([]byte(page))); got != want {
+		t.Errorf("trimHTML() = %q, want %q", got, want)
+	}
+}
+
+func TestFixturesTrimmed(t *testing.T) {
+	pages, _ := filepath.Glob(filepath.Join("testdata", "*", "*.html"))
+	for _, page := range pages {
+		body, err := os.ReadFile(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(trimHTML(body), body) {
+			t.Errorf("%s is not trimmed, record it with -record", page)
+		}
+	}
+}
+
+func TestScrapeBillboardHot100_LayoutChanged(t *testing.T) {
+	if *record {
+		t.Skip("hand-written fixture")
+	}
+	replay(t, "billboard_layout_changed")
+	_, err := ScrapeBillboardHot100(context.Background())
+	if !errors.Is(err, ErrLayoutChanged) {
+		t.Errorf("Expected ErrLayoutChanged for a page without chart rows, got %v", err)
+	}
+}
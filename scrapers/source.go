@@ -0,0 +1,185 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	fs "melodex/firestore"
+	spot "melodex/spotify"
+)
+
+// Source is a music source that is scraped into a daily snapshot.
+// Sources register themselves from an init func in their own file, so
+// adding a scraper never requires touching the router, scoring or cleanup.
+type Source interface {
+	// Name is the human readable name used in logs.
+	Name() string
+	// Target is the slug accepted by POST /scrape.
+	Target() string
+	// Collection is the Firestore collection daily snapshots are written to.
+	Collection() string
+	// Weight is the scoring weight applied to tracks from this source.
+	Weight() float64
+	// Cadence is a cron expression describing how often the source changes.
+	// An empty cadence marks a manual-only source that run-all scrapes skip.
+	Cadence() string
+	// Expectations describe a healthy scrape, checked after every fetch.
+	Expectations() Expectations
+	// RequiresID reports whether tracks that end up with neither an ISRC nor
+	// a MusicBrainz ID after enrichment are dropped from the snapshot.
+	RequiresID() bool
+	// Fetch scrapes the source and returns its songs in rank order.
+	Fetch(ctx context.Context) ([]fs.Song, error)
+}
+
+// Deps holds the shared clients a source may need to fetch.
+type Deps struct {
+	Spotify *spot.SpotifyClient
+}
+
+// Binder is implemented by sources that need shared clients before Fetch.
+type Binder interface {
+	Bind(deps Deps)
+}
+
+// SourceInfo is the static description of a source.
+type SourceInfo struct {
+	Name         string
+	Target       string
+	Collection   string
+	Weight       float64
+	Cadence      string
+	Expectations Expectations
+	RequireID    bool
+}
+
+// FetchFunc scrapes a source using the bound dependencies.
+type FetchFunc func(ctx context.Context, deps Deps) ([]fs.Song, error)
+
+// NewSource builds a Source from its static description and a fetch func.
+func NewSource(info SourceInfo, fetch FetchFunc) Source {
+	return &funcSource{info: info, fetch: fetch}
+}
+
+type funcSource struct {
+	info  SourceInfo
+	fetch FetchFunc
+	deps  Deps
+}
+
+func (s *funcSource) Name() string       { return s.info.Name }
+func (s *funcSource) Target() string     { return s.info.Target }
+func (s *funcSource) Collection() string { return s.info.Collection }
+func (s *funcSource) Weight() float64    { return s.info.Weight }
+func (s *funcSource) Cadence() string    { return s.info.Cadence }
+func (s *funcSource) Bind(deps Deps)     { s.deps = deps }
+
+func (s *funcSource) Expectations() Expectations { return s.info.Expectations }
+func (s *funcSource) RequiresID() bool           { return s.info.RequireID }
+
+func (s *funcSource) Fetch(ctx context.Context) ([]fs.Song, error) {
+	return s.fetch(ctx, s.deps)
+}
+
+// Registry holds every known source in registration order.
+type Registry struct {
+	mu      sync.RWMutex
+	sources []Source
+}
+
+var defaultRegistry = &Registry{}
+
+// Default returns the registry that sources register into from init.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register adds a source to the default registry.
+func Register(s Source) {
+	defaultRegistry.Register(s)
+}
+
+// Register adds a source, panicking if its target or collection is taken.
+func (r *Registry) Register(s Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.sources {
+		if existing.Target() == s.Target() {
+			panic(fmt.Sprintf("scrapers: duplicate source target %q", s.Target()))
+		}
+		if existing.Collection() == s.Collection() {
+			panic(fmt.Sprintf("scrapers: duplicate source collection %q", s.Collection()))
+		}
+	}
+	r.sources = append(r.sources, s)
+}
+
+// Bind hands shared clients to every source that needs them.
+func (r *Registry) Bind(deps Deps) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.sources {
+		if b, ok := s.(Binder); ok {
+			b.Bind(deps)
+		}
+	}
+}
+
+// All returns every registered source.
+func (r *Registry) All() []Source {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Source(nil), r.sources...)
+}
+
+// Scheduled returns the sources that take part in run-all scrapes.
+func (r *Registry) Scheduled() []Source {
+	var scheduled []Source
+	for _, s := range r.All() {
+		if s.Cadence() != "" {
+			scheduled = append(scheduled, s)
+		}
+	}
+	return scheduled
+}
+
+// ByTarget looks up a source by its /scrape target slug.
+func (r *Registry) ByTarget(target string) (Source, bool) {
+	for _, s := range r.All() {
+		if s.Target() == target {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// ByCollection looks up a source by its Firestore collection.
+func (r *Registry) ByCollection(collection string) (Source, bool) {
+	for _, s := range r.All() {
+		if s.Collection() == collection {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Collections returns the Firestore collections of every registered source.
+func (r *Registry) Collections() []string {
+	sources := r.All()
+	collections := make([]string, 0, len(sources))
+	for _, s := range sources {
+		collections = append(collections, s.Collection())
+	}
+	return collections
+}
+
+// ProvideRegistry binds shared clients to the default registry and returns it.
+func ProvideRegistry(sp *spot.SpotifyClient) *Registry {
+	defaultRegistry.Bind(Deps{Spotify: sp})
+	return defaultRegistry
+}
+
+var Options = ProvideRegistry
@@ -2,10 +2,10 @@ package scrapers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
-	"net/http"
 	"strings"
-	"time"
 
 	fs "melodex/firestore"
 	spot "melodex/spotify"
@@ -15,21 +15,31 @@ import (
 
 const maxTracksPerArtist = 2
 
+func init() {
+	Register(NewSource(SourceInfo{
+		Name:         "Spotify New Releases",
+		Target:       "spotify-new-releases",
+		Collection:   "spotify_new_releases",
+		Weight:       1.0,
+		Cadence:      "0 6 * * 5", // New releases drop on Fridays
+		Expectations: Expectations{MinSongs: 20, MaxSongs: 100, ContiguousRanks: true},
+	}, func(ctx context.Context, deps Deps) ([]fs.Song, error) {
+		return ScrapeSpotifyNewReleases(ctx, deps.Spotify)
+	}))
+}
+
 // ScrapeSpotifyNewReleases fetches new releases from Spotify API.
 // Limits to maxTracksPerArtist per artist to avoid album explosion.
-func ScrapeSpotifyNewReleases(w http.ResponseWriter, sp *spot.SpotifyClient) ([]fs.Track, error) {
-	ctx := context.Background()
-	var tracks []fs.Track
+// Spotify already knows the identifiers, so they are passed on to enrichment.
+func ScrapeSpotifyNewReleases(ctx context.Context, sp *spot.SpotifyClient) ([]fs.Song, error) {
+	var tracks []fs.Song
 	artistCount := make(map[string]int) // track count per artist
 
 	// Get new releases (albums)
 	newReleases, err := sp.Client.NewReleases(ctx, spotify.Limit(50))
 	if err != nil {
 		log.Printf("Error getting new releases from Spotify: %v", err)
-		if w != nil {
-			http.Error(w, "Error getting new releases from Spotify", http.StatusInternalServerError)
-		}
-		return nil, err
+		return nil, spotifyError(err)
 	}
 
 	rank := 1
@@ -68,15 +78,21 @@ func ScrapeSpotifyNewReleases(w http.ResponseWriter, sp *spot.SpotifyClient) ([]
 				}
 			}
 
-			newTrack := fs.Track{
+			// Keep every artist with its Spotify ID, the first is the primary one
+			credits := make([]fs.ArtistCredit, len(track.Artists))
+			for i, artist := range track.Artists {
+				credits[i] = fs.ArtistCredit{Name: artist.Name, Role: fs.RoleFeatured, SpotifyID: artist.ID.String()}
+			}
+			credits[0].Role = fs.RolePrimary
+
+			newTrack := fs.Song{
 				Rank:      rank,
 				Artist:    artistName,
 				Title:     track.Name,
 				ISRC:      isrc,
 				SpotifyID: track.ID.String(),
 				Thumb:     thumb,
-				Source:    "spotify_new_releases",
-				CreatedAt: time.Now(),
+				Artists:   credits,
 			}
 
 			tracks = append(tracks, newTrack)
@@ -98,4 +114,13 @@ func ScrapeSpotifyNewReleases(w http.ResponseWriter, sp *spot.SpotifyClient) ([]
 	return tracks, nil
 }
 
-type ScrapeSpotifyNewReleasesFunc func(http.ResponseWriter, *spot.SpotifyClient) ([]fs.Track, error)
\ No newline at end of file
+type ScrapeSpotifyNewReleasesFunc func(context.Context, *spot.SpotifyClient) ([]fs.Song, error)
+
+// spotifyError converts a Spotify API error into a StatusError
+func spotifyError(err error) error {
+	var spErr spotify.Error
+	if errors.As(err, &spErr) && spErr.Status != 0 {
+		return fmt.Errorf("%w: %s", &StatusError{URL: "api.spotify.com", StatusCode: spErr.Status}, spErr.Message)
+	}
+	return err
+}
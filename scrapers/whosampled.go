@@ -2,19 +2,27 @@ package scrapers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"html"
 	"log"
 	fs "melodex/firestore"
-	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/chromedp/chromedp"
 )
 
-// ScrapeWhoSampled scrapes the WhoSampled.
-func ScrapeWhoSampled(w http.ResponseWriter, q string) ([]fs.Track, error) {
-	var songs []fs.Track
-	ctx, cancel := chromedp.NewContext(context.Background())
+// whoSampledSearchURL is the WhoSampled search endpoint, without the query
+const whoSampledSearchURL = "https://www.whosampled.com/ajax/search/?q="
+
+// renderPage loads a page in a headless browser and returns its rendered
+// body, as WhoSampled turns away plain HTTP clients. Tests replace it to
+// replay fixtures.
+var renderPage = func(ctx context.Context, pageURL string) (string, error) {
+	ctx, cancel := chromedp.NewContext(ctx)
 	defer cancel()
 
 	// Set a timeout
@@ -22,20 +30,62 @@ func ScrapeWhoSampled(w http.ResponseWriter, q string) ([]fs.Track, error) {
 	defer cancel()
 
 	var result string
-
-	// Run Chromedp tasks
 	err := chromedp.Run(ctx,
-		chromedp.Navigate("https://www.whosampled.com/ajax/search/?q=please%20please%20please"),
+		chromedp.Navigate(pageURL),
 		chromedp.WaitVisible(`body`, chromedp.ByQuery),
 		chromedp.InnerHTML(`body`, &result, chromedp.ByQuery),
 	)
+	return result, err
+}
+
+// ScrapeWhoSampled searches WhoSampled for q and returns the matching tracks.
+func ScrapeWhoSampled(ctx context.Context, q string) ([]fs.Song, error) {
+	searchURL := whoSampledSearchURL + url.QueryEscape(q)
+	body, err := renderPage(ctx, searchURL)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("whosampled search %q: %w", q, err)
 	}
 
-	// Print the result
-	fmt.Println("Page Content:")
-	fmt.Println(result)
+	log.Printf("WhoSampled search %q returned %d bytes", q, len(body))
+	return parseWhoSampledSearch(body, searchURL)
+}
+
+// whoSampledSearch is the part of a search response the scraper reads
+type whoSampledSearch struct {
+	Tracks []struct {
+		TrackName  string `json:"track_name"`
+		ArtistName string `json:"artist_name"`
+		ImageURL   string `json:"image_url"`
+	} `json:"tracks"`
+}
 
+// htmlTags matches the markup the browser wraps a JSON response in
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+// parseWhoSampledSearch reads the tracks of a rendered search response,
+// which the browser shows as JSON inside a <pre>
+func parseWhoSampledSearch(body, searchURL string) ([]fs.Song, error) {
+	text := html.UnescapeString(htmlTags.ReplaceAllString(body, ""))
+
+	var resp whoSampledSearch
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &resp); err != nil {
+		return nil, fmt.Errorf("decoding whosampled search response: %w: %w", ErrLayoutChanged, err)
+	}
+
+	songs := make([]fs.Song, 0, len(resp.Tracks))
+	for _, track := range resp.Tracks {
+		if track.TrackName == "" || track.ArtistName == "" {
+			continue
+		}
+		songs = append(songs, fs.Song{
+			Rank:   len(songs) + 1,
+			Title:  track.TrackName,
+			Artist: track.ArtistName,
+			Thumb:  track.ImageURL,
+		})
+	}
+	if len(resp.Tracks) > 0 && len(songs) == 0 {
+		return nil, layoutChanged("whosampled", searchURL)
+	}
 	return songs, nil
 }
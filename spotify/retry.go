@@ -0,0 +1,126 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// maxAttempts bounds the tries of a request
+	maxAttempts = 4
+	// initialBackoff is the first wait between tries, doubled every retry
+	initialBackoff = 500 * time.Millisecond
+	// maxRetryWait is the longest Retry-After waited for; longer ones fail
+	// the request instead of stalling a scrape
+	maxRetryWait = time.Minute
+)
+
+// retryTransport retries requests that were rate limited (429), failed on
+// Spotify's side (5xx) or didn't reach it, waiting for Retry-After when
+// given and backing off exponentially otherwise.
+type retryTransport struct {
+	base   http.RoundTripper
+	health *healthTracker // Optional
+
+	backoff time.Duration // initialBackoff if zero
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	backoff := t.backoff
+	if backoff <= 0 {
+		backoff = initialBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests && t.health != nil {
+			t.health.rateLimited()
+		}
+
+		wait, retry := retryWait(resp, err, backoff, time.Now())
+		if !retry || attempt == maxAttempts || ctx.Err() != nil || (req.Body != nil && req.GetBody == nil) {
+			t.record(ctx, resp, err)
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+// record reports the final outcome of a request to the health tracker.
+// Cancelled requests say nothing about Spotify.
+func (t *retryTransport) record(ctx context.Context, resp *http.Response, err error) {
+	switch {
+	case t.health == nil || ctx.Err() != nil:
+	case err != nil:
+		t.health.failure(err)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		t.health.failure(fmt.Errorf("spotify returned status %d", resp.StatusCode))
+	default:
+		t.health.success()
+	}
+}
+
+// retryWait decides whether a try is retried, and after how long
+func retryWait(resp *http.Response, err error, backoff time.Duration, now time.Time) (time.Duration, bool) {
+	if err != nil {
+		// Rejected credentials won't get better by retrying
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.Response != nil && retrieveErr.Response.StatusCode < 500 {
+			return 0, false
+		}
+		return backoff, true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+		if !ok {
+			return backoff, true
+		}
+		return wait, wait <= maxRetryWait
+	case resp.StatusCode >= 500:
+		return backoff, true
+	default:
+		return 0, false
+	}
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
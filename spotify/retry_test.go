@@ -0,0 +1,133 @@
+package spotify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// newTestClient returns a client for server authenticated like ProvideSpotify's
+func newTestClient(health *healthTracker) *http.Client {
+	return &http.Client{Transport: &retryTransport{
+		base:    &oauth2.Transport{Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})},
+		health:  health,
+		backoff: time.Millisecond,
+	}}
+}
+
+func TestRetryTransport_RetriesRateLimits(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Unexpected Authorization %q", r.Header.Get("Authorization"))
+		}
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	health := &healthTracker{}
+	resp, err := newTestClient(health).Get(server.URL)
+	if err != nil || resp.StatusCode != http.StatusOK || requests != 2 {
+		t.Fatalf("Expected a retry after the 429, got %v, %v after %d requests", resp, err, requests)
+	}
+	c := &SpotifyClient{health: health}
+	if h := c.Health(); h.Status != HealthOK || h.RateLimited != 1 {
+		t.Errorf("Unexpected health %+v", h)
+	}
+}
+
+func TestRetryTransport_GivesUp(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		requests   int
+		health     string
+	}{
+		{"server errors", http.StatusBadGateway, "", maxAttempts, HealthDegraded},
+		{"long Retry-After", http.StatusTooManyRequests, "3600", 1, HealthDegraded},
+		{"client errors", http.StatusNotFound, "", 1, HealthOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			health := &healthTracker{}
+			resp, err := newTestClient(health).Get(server.URL)
+			if err != nil || resp.StatusCode != tt.status || requests != tt.requests {
+				t.Errorf("Expected status %d after %d requests, got %v, %v after %d", tt.status, tt.requests, resp, err, requests)
+			}
+			c := &SpotifyClient{health: health}
+			if h := c.Health(); h.Status != tt.health {
+				t.Errorf("Expected health %s, got %+v", tt.health, h)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_StopsWhenCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	health := &healthTracker{}
+	if _, err := newTestClient(health).Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait for Retry-After to be cancelled, got %v", err)
+	}
+	if c := (&SpotifyClient{health: health}); c.Health().ConsecutiveFailures != 0 {
+		t.Errorf("Expected a cancelled request not to count as a failure, got %+v", c.Health())
+	}
+}
+
+func TestHealth_Unknown(t *testing.T) {
+	if h := (&SpotifyClient{}).Health(); h.Status != HealthUnknown {
+		t.Errorf("Expected unknown health without a tracker, got %+v", h)
+	}
+	if h := (&SpotifyClient{health: &healthTracker{}}).Health(); h.Status != HealthUnknown {
+		t.Errorf("Expected unknown health before any request, got %+v", h)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 2, 4, 16, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"2", 2 * time.Second, true},
+		{"Sun, 04 Feb 2024 16:00:10 GMT", 10 * time.Second, true},
+		{"", 0, false},
+		{"later", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseRetryAfter(tt.value, now); got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -2,44 +2,130 @@ package spotify
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net/http"
+	"sync"
+	"time"
 
 	"melodex/config"
 
 	"github.com/zmb3/spotify/v2"
 	spotifyauth "github.com/zmb3/spotify/v2/auth"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
 )
 
+// tokenTimeout bounds a token request, which runs outside any request's context
+const tokenTimeout = 10 * time.Second
+
+// Spotify health statuses
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded" // The last request, or the first token fetch, failed
+	HealthUnknown  = "unknown"  // Nothing was requested yet
+)
+
 type SpotifyClient struct {
 	ID     string
 	Secret string
 	Client *spotify.Client
+
+	health *healthTracker // Nil for clients built around another http.Client
 }
 
-func ProvideSpotify(cfg config.Config) *SpotifyClient {
-	ctx := context.Background()
+// Health describes how the latest requests to Spotify went
+type Health struct {
+	Status              string    `json:"status"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	RateLimited         int64     `json:"rateLimited"` // 429 responses
+	LastError           string    `json:"lastError,omitempty"`
+	LastErrorAt         time.Time `json:"lastErrorAt,omitempty"`
+	LastSuccessAt       time.Time `json:"lastSuccessAt,omitempty"`
+}
 
-	var c SpotifyClient
+// healthTracker records the outcome of every request and token fetch
+type healthTracker struct {
+	mu     sync.Mutex
+	health Health
+}
 
-	c.ID = cfg.SpotifyID
-	c.Secret = cfg.SpotifySecret
+func (t *healthTracker) success() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.health.ConsecutiveFailures = 0
+	t.health.LastSuccessAt = time.Now()
+}
 
-	config := &clientcredentials.Config{
+func (t *healthTracker) failure(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.health.ConsecutiveFailures++
+	t.health.LastError = err.Error()
+	t.health.LastErrorAt = time.Now()
+}
+
+func (t *healthTracker) rateLimited() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.health.RateLimited++
+}
+
+// ProvideSpotify provides a client authenticated with client credentials.
+// The token is fetched on first use and refreshed before it expires, so an
+// unreachable Spotify degrades the service instead of stopping it.
+func ProvideSpotify(cfg config.Config) *SpotifyClient {
+	c := &SpotifyClient{
+		ID:     cfg.SpotifyID,
+		Secret: cfg.SpotifySecret,
+		health: &healthTracker{},
+	}
+
+	credentials := &clientcredentials.Config{
 		ClientID:     c.ID,
 		ClientSecret: c.Secret,
 		TokenURL:     spotifyauth.TokenURL,
 	}
-	token, err := config.Token(ctx)
-	if err != nil {
-		log.Fatalf("couldn't get token: %v", err)
-	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: tokenTimeout})
+	tokens := credentials.TokenSource(ctx)
 
-	httpClient := spotifyauth.New().Client(ctx, token)
-	client := spotify.New(httpClient)
-	c.Client = client
+	c.Client = spotify.New(&http.Client{
+		Transport: &retryTransport{
+			base:   &oauth2.Transport{Source: tokens, Base: http.DefaultTransport},
+			health: c.health,
+		},
+	})
 
-	return &c
+	// Report an unreachable Spotify in /health without waiting for a request
+	go func() {
+		if _, err := tokens.Token(); err != nil {
+			log.Printf("Spotify is unreachable, continuing degraded: %v", err)
+			c.health.failure(fmt.Errorf("token: %w", err))
+			return
+		}
+		c.health.success()
+	}()
+	return c
+}
+
+// Health reports how the latest requests to Spotify went
+func (c *SpotifyClient) Health() Health {
+	if c.health == nil {
+		return Health{Status: HealthUnknown}
+	}
+
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	health := c.health.health
+	switch {
+	case health.ConsecutiveFailures > 0:
+		health.Status = HealthDegraded
+	case health.LastSuccessAt.IsZero():
+		health.Status = HealthUnknown
+	default:
+		health.Status = HealthOK
+	}
+	return health
 }
 
 var Options = ProvideSpotify
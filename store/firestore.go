@@ -0,0 +1,370 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	fs "melodex/firestore"
+)
+
+// Firestore stores documents in Google Cloud Firestore.
+type Firestore struct {
+	client *firestore.Client
+}
+
+// NewFirestore creates a Store backed by a Firestore client
+func NewFirestore(client *firestore.Client) *Firestore {
+	return &Firestore{client: client}
+}
+
+func (s *Firestore) GetSnapshot(ctx context.Context, collection, date string) (fs.Snapshot, error) {
+	var snapshot fs.Snapshot
+	doc, err := s.client.Collection(collection).Doc(date).Get(ctx)
+	if err != nil {
+		return snapshot, notFound(err)
+	}
+
+	if err := doc.DataTo(&snapshot); err != nil {
+		return snapshot, err
+	}
+	snapshot.UpdatedAt = doc.UpdateTime
+	return snapshot, nil
+}
+
+func (s *Firestore) SnapshotExists(ctx context.Context, collection, date string) (bool, error) {
+	doc, err := s.client.Collection(collection).Doc(date).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return doc.Exists(), nil
+}
+
+func (s *Firestore) SaveSnapshot(ctx context.Context, collection, date string, tracks []fs.Track) error {
+	_, err := s.client.Collection(collection).Doc(date).Set(ctx, fs.Snapshot{Tracks: tracks})
+	return err
+}
+
+func (s *Firestore) ListSnapshotDates(ctx context.Context, collection string) ([]string, error) {
+	iter := s.client.Collection(collection).DocumentRefs(ctx)
+	var dates []string
+	for {
+		ref, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, ref.ID)
+	}
+	sort.Strings(dates)
+	return dates, nil
+}
+
+func (s *Firestore) Cleanup(ctx context.Context, collections []string, maxAge time.Duration) error {
+	return fs.CleanupOldDocuments(ctx, s.client, collections, maxAge)
+}
+
+func (s *Firestore) GetDiscovery(ctx context.Context, date string) (fs.Discovery, error) {
+	var feed fs.Discovery
+	doc, err := s.client.Collection(DiscoveryCollection).Doc(date).Get(ctx)
+	if err != nil {
+		return feed, notFound(err)
+	}
+	err = doc.DataTo(&feed)
+	return feed, err
+}
+
+func (s *Firestore) SaveDiscovery(ctx context.Context, feed fs.Discovery) error {
+	_, err := s.client.Collection(DiscoveryCollection).Doc(feed.Date).Set(ctx, feed)
+	return err
+}
+
+func (s *Firestore) GetTrack(ctx context.Context, id string) (fs.CanonicalTrack, error) {
+	var track fs.CanonicalTrack
+	doc, err := s.client.Collection(TracksCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return track, notFound(err)
+	}
+	err = doc.DataTo(&track)
+	return track, err
+}
+
+func (s *Firestore) SaveTrack(ctx context.Context, track fs.CanonicalTrack) error {
+	_, err := s.client.Collection(TracksCollection).Doc(track.ID).Set(ctx, track)
+	return err
+}
+
+// UpdateTracks runs fn in a transaction, so concurrent scrapes never lose
+// each other's appearances
+func (s *Firestore) UpdateTracks(ctx context.Context, ids []string, fn func(tracks map[string]*fs.CanonicalTrack) error) error {
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		docs, err := tx.GetAll(s.refs(TracksCollection, ids))
+		if err != nil {
+			return err
+		}
+		tracks := make(map[string]*fs.CanonicalTrack)
+		for _, doc := range docs {
+			if !doc.Exists() {
+				continue
+			}
+			var track fs.CanonicalTrack
+			if err := doc.DataTo(&track); err != nil {
+				return err
+			}
+			tracks[doc.Ref.ID] = &track
+		}
+
+		if err := fn(tracks); err != nil {
+			return err
+		}
+		for id, track := range tracks {
+			if err := tx.Set(s.client.Collection(TracksCollection).Doc(id), *track); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Firestore) GetArtist(ctx context.Context, id string) (fs.Artist, error) {
+	var artist fs.Artist
+	doc, err := s.client.Collection(ArtistsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return artist, notFound(err)
+	}
+	err = doc.DataTo(&artist)
+	return artist, err
+}
+
+func (s *Firestore) SaveArtist(ctx context.Context, artist fs.Artist) error {
+	_, err := s.client.Collection(ArtistsCollection).Doc(artist.ID).Set(ctx, artist)
+	return err
+}
+
+// UpdateArtists runs fn in a transaction, so concurrent scrapes never lose
+// each other's tracks
+func (s *Firestore) UpdateArtists(ctx context.Context, ids []string, fn func(artists map[string]*fs.Artist) error) error {
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		docs, err := tx.GetAll(s.refs(ArtistsCollection, ids))
+		if err != nil {
+			return err
+		}
+		artists := make(map[string]*fs.Artist)
+		for _, doc := range docs {
+			if !doc.Exists() {
+				continue
+			}
+			var artist fs.Artist
+			if err := doc.DataTo(&artist); err != nil {
+				return err
+			}
+			artists[doc.Ref.ID] = &artist
+		}
+
+		if err := fn(artists); err != nil {
+			return err
+		}
+		for id, artist := range artists {
+			if err := tx.Set(s.client.Collection(ArtistsCollection).Doc(id), *artist); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Firestore) GetEnrichment(ctx context.Context, id string) (fs.EnrichmentEntry, error) {
+	var entry fs.EnrichmentEntry
+	doc, err := s.client.Collection(EnrichmentCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return entry, notFound(err)
+	}
+	err = doc.DataTo(&entry)
+	return entry, err
+}
+
+func (s *Firestore) SaveEnrichment(ctx context.Context, entry fs.EnrichmentEntry) error {
+	_, err := s.client.Collection(EnrichmentCollection).Doc(entry.ID).Set(ctx, entry)
+	return err
+}
+
+// UpdateRateLimit runs fn in a transaction, so instances never reserve the
+// same slot
+func (s *Firestore) UpdateRateLimit(ctx context.Context, name string, fn func(state *fs.RateLimit) bool) error {
+	ref := s.client.Collection(RateLimitsCollection).Doc(name)
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		state := fs.RateLimit{Name: name}
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(&state); err != nil {
+				return err
+			}
+		}
+
+		if !fn(&state) {
+			return nil
+		}
+		state.UpdatedAt = time.Now()
+		return tx.Set(ref, state)
+	})
+}
+
+func (s *Firestore) GetPodcastShow(ctx context.Context, id string) (fs.PodcastShow, error) {
+	var show fs.PodcastShow
+	doc, err := s.client.Collection(PodcastShowsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return show, notFound(err)
+	}
+	err = doc.DataTo(&show)
+	return show, err
+}
+
+func (s *Firestore) SavePodcastShow(ctx context.Context, show fs.PodcastShow) error {
+	_, err := s.client.Collection(PodcastShowsCollection).Doc(show.ID).Set(ctx, show)
+	return err
+}
+
+func (s *Firestore) DeletePodcastShows(ctx context.Context) error {
+	iter := s.client.Collection(PodcastShowsCollection).Documents(ctx)
+	defer iter.Stop()
+
+	batch := s.client.Batch()
+	batchCount := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		batch.Delete(doc.Ref)
+		batchCount++
+		// Firestore caps batches at 500 writes
+		if batchCount >= 500 {
+			if _, err := batch.Commit(ctx); err != nil {
+				return err
+			}
+			batch = s.client.Batch()
+			batchCount = 0
+		}
+	}
+	if batchCount > 0 {
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Firestore) SaveRun(ctx context.Context, run fs.ScrapeRun) error {
+	_, err := s.client.Collection(ScrapeRunsCollection).Doc(run.ID).Set(ctx, run)
+	return err
+}
+
+// ListRuns needs the composite index on source and startedAt created by
+// `make indexes` when filtering by source
+func (s *Firestore) ListRuns(ctx context.Context, filter RunFilter) ([]fs.ScrapeRun, error) {
+	q := s.client.Collection(ScrapeRunsCollection).OrderBy("startedAt", firestore.Desc)
+	if filter.Source != "" {
+		q = q.Where("source", "==", filter.Source)
+	}
+	if !filter.Since.IsZero() {
+		q = q.Where("startedAt", ">=", filter.Since)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+
+	iter := q.Documents(ctx)
+	defer iter.Stop()
+
+	var runs []fs.ScrapeRun
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var run fs.ScrapeRun
+		if err := doc.DataTo(&run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+func (s *Firestore) SaveJob(ctx context.Context, job fs.ScrapeJob) error {
+	_, err := s.client.Collection(ScrapeJobsCollection).Doc(job.ID).Set(ctx, job)
+	return err
+}
+
+func (s *Firestore) GetJob(ctx context.Context, id string) (fs.ScrapeJob, error) {
+	var job fs.ScrapeJob
+	doc, err := s.client.Collection(ScrapeJobsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return job, notFound(err)
+	}
+	err = doc.DataTo(&job)
+	return job, err
+}
+
+func (s *Firestore) ListJobs(ctx context.Context, limit int) ([]fs.ScrapeJob, error) {
+	q := s.client.Collection(ScrapeJobsCollection).OrderBy("createdAt", firestore.Desc)
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+
+	iter := q.Documents(ctx)
+	defer iter.Stop()
+
+	var jobs []fs.ScrapeJob
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var job fs.ScrapeJob
+		if err := doc.DataTo(&job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// refs returns the references of the documents with the given IDs
+func (s *Firestore) refs(collection string, ids []string) []*firestore.DocumentRef {
+	refs := make([]*firestore.DocumentRef, len(ids))
+	for i, id := range ids {
+		refs[i] = s.client.Collection(collection).Doc(id)
+	}
+	return refs
+}
+
+// notFound maps Firestore's NotFound status to ErrNotFound
+func notFound(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	return err
+}
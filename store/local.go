@@ -0,0 +1,369 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	mfs "melodex/firestore"
+)
+
+// Local stores every document as a JSON file under dir/<collection>/<id>.json,
+// so the service can run without GCP credentials.
+type Local struct {
+	mu  sync.RWMutex
+	dir string
+
+	// Held across the read-modify-write of canonical tracks and artists
+	catalogMu sync.Mutex
+
+	// Rate limits only coordinate this process, so they stay in memory
+	rateLimitsMu sync.Mutex
+	rateLimits   map[string]mfs.RateLimit
+}
+
+// NewLocal creates a file-backed Store rooted at dir
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir, rateLimits: make(map[string]mfs.RateLimit)}
+}
+
+func (s *Local) GetSnapshot(ctx context.Context, collection, date string) (mfs.Snapshot, error) {
+	var snapshot mfs.Snapshot
+	if err := s.read(collection, date, &snapshot); err != nil {
+		return snapshot, err
+	}
+
+	// The file's modification time stands in for Firestore's update time
+	s.mu.RLock()
+	info, err := os.Stat(s.path(collection, date))
+	s.mu.RUnlock()
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.UpdatedAt = info.ModTime()
+	return snapshot, nil
+}
+
+func (s *Local) SnapshotExists(ctx context.Context, collection, date string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, err := os.Stat(s.path(collection, date))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Local) SaveSnapshot(ctx context.Context, collection, date string, tracks []mfs.Track) error {
+	return s.write(collection, date, mfs.Snapshot{Tracks: tracks})
+}
+
+func (s *Local) ListSnapshotDates(ctx context.Context, collection string) ([]string, error) {
+	return s.list(collection)
+}
+
+func (s *Local) Cleanup(ctx context.Context, collections []string, maxAge time.Duration) error {
+	cutoffStr := time.Now().Add(-maxAge).Format("2006-01-02")
+	totalDeleted := 0
+
+	for _, collName := range collections {
+		dates, err := s.list(collName)
+		if err != nil {
+			log.Printf("Error listing %s: %v", collName, err)
+			continue
+		}
+		for _, docDate := range dates {
+			if docDate >= cutoffStr {
+				continue
+			}
+			if err := s.remove(collName, docDate); err != nil {
+				log.Printf("Error deleting %s/%s: %v", collName, docDate, err)
+				continue
+			}
+			log.Printf("Deleted old document: %s/%s", collName, docDate)
+			totalDeleted++
+		}
+	}
+
+	log.Printf("Cleanup complete: deleted %d documents older than %s", totalDeleted, cutoffStr)
+	return nil
+}
+
+func (s *Local) GetDiscovery(ctx context.Context, date string) (mfs.Discovery, error) {
+	var feed mfs.Discovery
+	err := s.read(DiscoveryCollection, date, &feed)
+	return feed, err
+}
+
+func (s *Local) SaveDiscovery(ctx context.Context, feed mfs.Discovery) error {
+	return s.write(DiscoveryCollection, feed.Date, feed)
+}
+
+func (s *Local) GetTrack(ctx context.Context, id string) (mfs.CanonicalTrack, error) {
+	var track mfs.CanonicalTrack
+	err := s.read(TracksCollection, id, &track)
+	return track, err
+}
+
+func (s *Local) SaveTrack(ctx context.Context, track mfs.CanonicalTrack) error {
+	return s.write(TracksCollection, track.ID, track)
+}
+
+func (s *Local) UpdateTracks(ctx context.Context, ids []string, fn func(tracks map[string]*mfs.CanonicalTrack) error) error {
+	s.catalogMu.Lock()
+	defer s.catalogMu.Unlock()
+
+	tracks := make(map[string]*mfs.CanonicalTrack)
+	for _, id := range ids {
+		var track mfs.CanonicalTrack
+		err := s.read(TracksCollection, id, &track)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		tracks[id] = &track
+	}
+
+	if err := fn(tracks); err != nil {
+		return err
+	}
+	for id, track := range tracks {
+		if err := s.write(TracksCollection, id, track); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Local) GetArtist(ctx context.Context, id string) (mfs.Artist, error) {
+	var artist mfs.Artist
+	err := s.read(ArtistsCollection, id, &artist)
+	return artist, err
+}
+
+func (s *Local) SaveArtist(ctx context.Context, artist mfs.Artist) error {
+	return s.write(ArtistsCollection, artist.ID, artist)
+}
+
+func (s *Local) UpdateArtists(ctx context.Context, ids []string, fn func(artists map[string]*mfs.Artist) error) error {
+	s.catalogMu.Lock()
+	defer s.catalogMu.Unlock()
+
+	artists := make(map[string]*mfs.Artist)
+	for _, id := range ids {
+		var artist mfs.Artist
+		err := s.read(ArtistsCollection, id, &artist)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		artists[id] = &artist
+	}
+
+	if err := fn(artists); err != nil {
+		return err
+	}
+	for id, artist := range artists {
+		if err := s.write(ArtistsCollection, id, artist); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Local) GetEnrichment(ctx context.Context, id string) (mfs.EnrichmentEntry, error) {
+	var entry mfs.EnrichmentEntry
+	err := s.read(EnrichmentCollection, id, &entry)
+	return entry, err
+}
+
+func (s *Local) SaveEnrichment(ctx context.Context, entry mfs.EnrichmentEntry) error {
+	return s.write(EnrichmentCollection, entry.ID, entry)
+}
+
+func (s *Local) UpdateRateLimit(ctx context.Context, name string, fn func(state *mfs.RateLimit) bool) error {
+	s.rateLimitsMu.Lock()
+	defer s.rateLimitsMu.Unlock()
+
+	state, ok := s.rateLimits[name]
+	if !ok {
+		state.Name = name
+	}
+	if fn(&state) {
+		state.UpdatedAt = time.Now()
+		s.rateLimits[name] = state
+	}
+	return nil
+}
+
+func (s *Local) GetPodcastShow(ctx context.Context, id string) (mfs.PodcastShow, error) {
+	var show mfs.PodcastShow
+	err := s.read(PodcastShowsCollection, id, &show)
+	return show, err
+}
+
+func (s *Local) SavePodcastShow(ctx context.Context, show mfs.PodcastShow) error {
+	return s.write(PodcastShowsCollection, show.ID, show)
+}
+
+func (s *Local) DeletePodcastShows(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.RemoveAll(filepath.Join(s.dir, PodcastShowsCollection))
+}
+
+func (s *Local) SaveRun(ctx context.Context, run mfs.ScrapeRun) error {
+	return s.write(ScrapeRunsCollection, run.ID, run)
+}
+
+func (s *Local) ListRuns(ctx context.Context, filter RunFilter) ([]mfs.ScrapeRun, error) {
+	ids, err := s.list(ScrapeRunsCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []mfs.ScrapeRun
+	for _, id := range ids {
+		var run mfs.ScrapeRun
+		if err := s.read(ScrapeRunsCollection, id, &run); err != nil {
+			return nil, err
+		}
+		if filter.matches(run) {
+			runs = append(runs, run)
+		}
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	if filter.Limit > 0 && len(runs) > filter.Limit {
+		runs = runs[:filter.Limit]
+	}
+	return runs, nil
+}
+
+func (s *Local) SaveJob(ctx context.Context, job mfs.ScrapeJob) error {
+	return s.write(ScrapeJobsCollection, job.ID, job)
+}
+
+func (s *Local) GetJob(ctx context.Context, id string) (mfs.ScrapeJob, error) {
+	var job mfs.ScrapeJob
+	err := s.read(ScrapeJobsCollection, id, &job)
+	return job, err
+}
+
+func (s *Local) ListJobs(ctx context.Context, limit int) ([]mfs.ScrapeJob, error) {
+	ids, err := s.list(ScrapeJobsCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]mfs.ScrapeJob, 0, len(ids))
+	for _, id := range ids {
+		var job mfs.ScrapeJob
+		if err := s.read(ScrapeJobsCollection, id, &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+func (s *Local) path(collection, id string) string {
+	return filepath.Join(s.dir, collection, id+".json")
+}
+
+func (s *Local) read(collection, id string, v interface{}) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(s.path(collection, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// write replaces a document atomically so readers never see a partial file
+func (s *Local) write(collection, id string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := filepath.Join(s.dir, collection)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(collection, id))
+}
+
+func (s *Local) remove(collection, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.Remove(s.path(collection, id))
+}
+
+// list returns the document IDs of a collection in ascending order
+func (s *Local) list(collection string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, collection))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	fs "melodex/firestore"
+)
+
+func TestLocal_Snapshots(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocal(t.TempDir())
+
+	if _, err := s.GetSnapshot(ctx, "billboard", "2024-02-04"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for missing snapshot, got %v", err)
+	}
+
+	tracks := []fs.Track{{Rank: 1, Artist: "SZA", Title: "Saturn", Source: "billboard"}}
+	if err := s.SaveSnapshot(ctx, "billboard", "2024-02-04", tracks); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+	if err := s.SaveSnapshot(ctx, "billboard", "2024-02-03", nil); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	exists, err := s.SnapshotExists(ctx, "billboard", "2024-02-04")
+	if err != nil || !exists {
+		t.Errorf("Expected snapshot to exist, got %v, %v", exists, err)
+	}
+
+	got, err := s.GetSnapshot(ctx, "billboard", "2024-02-04")
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	if len(got.Tracks) != 1 || got.Tracks[0].Title != "Saturn" {
+		t.Errorf("Unexpected snapshot contents: %+v", got)
+	}
+	if got.UpdatedAt.IsZero() {
+		t.Error("Expected the snapshot to carry its update time")
+	}
+
+	dates, err := s.ListSnapshotDates(ctx, "billboard")
+	if err != nil {
+		t.Fatalf("ListSnapshotDates: %v", err)
+	}
+	if len(dates) != 2 || dates[0] != "2024-02-03" {
+		t.Errorf("Expected ascending dates, got %v", dates)
+	}
+}
+
+func TestLocal_Cleanup(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocal(t.TempDir())
+
+	old := time.Now().Add(-10 * 24 * time.Hour).Format("2006-01-02")
+	today := time.Now().Format("2006-01-02")
+	s.SaveSnapshot(ctx, "hnhh", old, nil)
+	s.SaveSnapshot(ctx, "hnhh", today, nil)
+
+	if err := s.Cleanup(ctx, []string{"hnhh"}, fs.DefaultTTL); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+
+	dates, _ := s.ListSnapshotDates(ctx, "hnhh")
+	if len(dates) != 1 || dates[0] != today {
+		t.Errorf("Expected only today's snapshot to survive, got %v", dates)
+	}
+}
+
+func TestLocal_ListRuns(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocal(t.TempDir())
+
+	now := time.Now()
+	s.SaveRun(ctx, fs.ScrapeRun{ID: "a", Source: "billboard", StartedAt: now.Add(-2 * time.Hour)})
+	s.SaveRun(ctx, fs.ScrapeRun{ID: "b", Source: "hnhh", StartedAt: now.Add(-1 * time.Hour)})
+	s.SaveRun(ctx, fs.ScrapeRun{ID: "c", Source: "billboard", StartedAt: now})
+
+	runs, err := s.ListRuns(ctx, RunFilter{Source: "billboard"})
+	if err != nil {
+		t.Fatalf("ListRuns: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != "c" {
+		t.Errorf("Expected billboard runs newest first, got %+v", runs)
+	}
+
+	runs, _ = s.ListRuns(ctx, RunFilter{Since: now.Add(-90 * time.Minute), Limit: 1})
+	if len(runs) != 1 || runs[0].ID != "c" {
+		t.Errorf("Expected the newest run since the cutoff, got %+v", runs)
+	}
+}
+
+func TestLocal_UpdateRateLimit(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocal(t.TempDir())
+	tat := time.Now().Add(time.Second)
+
+	s.UpdateRateLimit(ctx, "musicbrainz", func(state *fs.RateLimit) bool {
+		state.TAT = tat
+		return true
+	})
+	s.UpdateRateLimit(ctx, "musicbrainz", func(state *fs.RateLimit) bool {
+		state.TAT = time.Time{}
+		return false // Discarded
+	})
+
+	var got fs.RateLimit
+	s.UpdateRateLimit(ctx, "musicbrainz", func(state *fs.RateLimit) bool {
+		got = *state
+		return false
+	})
+	if got.Name != "musicbrainz" || !got.TAT.Equal(tat) || got.UpdatedAt.IsZero() {
+		t.Errorf("Unexpected rate limit state %+v", got)
+	}
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"melodex/config"
+	fs "melodex/firestore"
+)
+
+// ErrNotFound is returned when a document does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Store is the persistence layer behind every handler. Daily snapshots
+// live in one collection per source, keyed by date (YYYY-MM-DD).
+type Store interface {
+	// GetSnapshot returns the document stored for a source on a date,
+	// including when it was last written.
+	GetSnapshot(ctx context.Context, collection, date string) (fs.Snapshot, error)
+	// SnapshotExists reports whether a source already has a document for a date.
+	SnapshotExists(ctx context.Context, collection, date string) (bool, error)
+	// SaveSnapshot writes (or replaces) a source's document for a date.
+	SaveSnapshot(ctx context.Context, collection, date string, tracks []fs.Track) error
+	// ListSnapshotDates returns the stored dates of a source in ascending order.
+	ListSnapshotDates(ctx context.Context, collection string) ([]string, error)
+	// Cleanup deletes snapshots older than maxAge from the given collections.
+	Cleanup(ctx context.Context, collections []string, maxAge time.Duration) error
+
+	// GetDiscovery returns the materialized discovery feed of a date.
+	GetDiscovery(ctx context.Context, date string) (fs.Discovery, error)
+	// SaveDiscovery writes (or replaces) the discovery feed of its date.
+	SaveDiscovery(ctx context.Context, feed fs.Discovery) error
+
+	// GetTrack returns a canonical track by its melodex ID.
+	GetTrack(ctx context.Context, id string) (fs.CanonicalTrack, error)
+	// SaveTrack creates or replaces a canonical track.
+	SaveTrack(ctx context.Context, track fs.CanonicalTrack) error
+	// UpdateTracks atomically applies fn to the canonical tracks with the
+	// given IDs, keyed by ID; missing ones are left out of the map. Every
+	// entry of the map is saved if fn succeeds. fn may run more than once.
+	UpdateTracks(ctx context.Context, ids []string, fn func(tracks map[string]*fs.CanonicalTrack) error) error
+
+	// GetArtist returns an artist by its melodex ID.
+	GetArtist(ctx context.Context, id string) (fs.Artist, error)
+	// SaveArtist creates or replaces an artist.
+	SaveArtist(ctx context.Context, artist fs.Artist) error
+	// UpdateArtists is UpdateTracks for artists.
+	UpdateArtists(ctx context.Context, ids []string, fn func(artists map[string]*fs.Artist) error) error
+
+	// GetEnrichment returns an enrichment cache entry by ID.
+	GetEnrichment(ctx context.Context, id string) (fs.EnrichmentEntry, error)
+	// SaveEnrichment creates or replaces an enrichment cache entry.
+	SaveEnrichment(ctx context.Context, entry fs.EnrichmentEntry) error
+
+	// UpdateRateLimit atomically applies fn to the state of a shared rate
+	// limit, saving it if fn returns true. fn may run more than once.
+	UpdateRateLimit(ctx context.Context, name string, fn func(state *fs.RateLimit) bool) error
+
+	// GetPodcastShow returns a show from the podcast catalog.
+	GetPodcastShow(ctx context.Context, id string) (fs.PodcastShow, error)
+	// SavePodcastShow creates or replaces a show in the podcast catalog.
+	SavePodcastShow(ctx context.Context, show fs.PodcastShow) error
+	// DeletePodcastShows empties the podcast catalog.
+	DeletePodcastShows(ctx context.Context) error
+
+	// SaveRun creates or replaces a scrape run record.
+	SaveRun(ctx context.Context, run fs.ScrapeRun) error
+	// ListRuns returns run records matching the filter, newest first.
+	ListRuns(ctx context.Context, filter RunFilter) ([]fs.ScrapeRun, error)
+
+	// SaveJob creates or replaces a scrape job.
+	SaveJob(ctx context.Context, job fs.ScrapeJob) error
+	// GetJob returns a scrape job by ID.
+	GetJob(ctx context.Context, id string) (fs.ScrapeJob, error)
+	// ListJobs returns the most recent scrape jobs, newest first.
+	ListJobs(ctx context.Context, limit int) ([]fs.ScrapeJob, error)
+}
+
+// RunFilter narrows ListRuns. Zero values match everything.
+type RunFilter struct {
+	Source string
+	Since  time.Time
+	Limit  int
+}
+
+// Collection names for documents that are not daily source snapshots
+const (
+	DiscoveryCollection    = fs.DiscoveryCollection // Keyed by date and TTL-cleaned like snapshots
+	TracksCollection       = "tracks"               // Canonical tracks by melodex ID, never TTL-cleaned
+	ArtistsCollection      = "artists"              // Artists by melodex ID, never TTL-cleaned
+	EnrichmentCollection   = "enrichment_cache"
+	RateLimitsCollection   = "rate_limits"
+	PodcastShowsCollection = "podcast_shows"
+	ScrapeRunsCollection   = "scrape_runs"
+	ScrapeJobsCollection   = "scrape_jobs"
+)
+
+// ProvideStore provides the storage backend selected by MELODEX_STORE
+func ProvideStore(cfg config.Config) Store {
+	switch cfg.Store {
+	case "local":
+		log.Printf("Using local store in %s", cfg.DataDir)
+		return NewLocal(cfg.DataDir)
+	case "firestore", "":
+		log.Printf("Using Firestore project %s", cfg.FirestoreProjectID)
+		return NewFirestore(fs.ProvideDB(cfg.FirestoreProjectID))
+	default:
+		log.Fatalf("Unknown store backend: %s", cfg.Store)
+		return nil
+	}
+}
+
+var Options = ProvideStore
+
+// matches reports whether a run passes the filter
+func (f RunFilter) matches(run fs.ScrapeRun) bool {
+	if f.Source != "" && run.Source != f.Source {
+		return false
+	}
+	if !f.Since.IsZero() && run.StartedAt.Before(f.Since) {
+		return false
+	}
+	return true
+}